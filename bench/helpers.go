@@ -48,3 +48,20 @@ func mwIsUserParam(userParam string) middleware.Middleware {
 var api_mws = []middleware.Middleware{mwCORS(), mwID}
 var api_mws_auth = []middleware.Middleware{mwIsUserParam("user"), mwCORS(), mwID}
 var api_rqs = []require.Required{require.Hosts("{.*}")}
+
+// mock response writer, taken from go-http-routing-benchmark
+type mockResponseWriter struct{}
+
+func (m *mockResponseWriter) Header() (h http.Header) {
+	return http.Header{}
+}
+
+func (m *mockResponseWriter) Write(p []byte) (n int, err error) {
+	return len(p), nil
+}
+
+func (m *mockResponseWriter) WriteString(s string) (n int, err error) {
+	return len(s), nil
+}
+
+func (m *mockResponseWriter) WriteHeader(int) {}
@@ -0,0 +1,221 @@
+package bench
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/decentplatforms/matcha/pkg/route"
+	"github.com/decentplatforms/matcha/pkg/router"
+)
+
+/*
+ * ===== MockBoards at Scale =====
+ *
+ * Use Case: MockBoards has grown, and its largest boards get their own endpoints, registered ahead of the
+ * generic API from v1_test.go. Each board has:
+ *
+ * - 4 static endpoints under /boards/<board>
+ * - 1 endpoint with a post ID param
+ *
+ * The boards share one wide level of the route tree, which is what this benchmark stresses.
+ *
+ * ===== Using This Benchmark =====
+ *
+ * Run the benchmarks for 1k and 10k routes. Requests are created ahead of time, so no offset is needed.
+ */
+
+// scaledRoutes gets n board routes, followed by the MockBoards API.
+func scaledRoutes(n int) []benchRoute {
+	routes := make([]benchRoute, 0, n+len(apiRoutes))
+	for i := 0; len(routes) < n; i++ {
+		board := fmt.Sprintf("/boards/board%d", i)
+		routes = append(routes,
+			benchRoute{method: http.MethodGet, path: board + "/posts", testPath: board + "/posts"},
+			benchRoute{method: http.MethodPut, path: board + "/posts", testPath: board + "/posts"},
+			benchRoute{method: http.MethodGet, path: board + "/posts/top", testPath: board + "/posts/top"},
+			benchRoute{method: http.MethodGet, path: board + "/posts/[id]", testPath: board + "/posts/2719"},
+			benchRoute{method: http.MethodGet, path: board + "/about", testPath: board + "/about"},
+		)
+	}
+	return append(routes, apiRoutes...)
+}
+
+// scaledRouter gets a router and test requests for a set of routes.
+func scaledRouter(tb testing.TB, routes []benchRoute) (router.Router, []*http.Request) {
+	rt := router.Default()
+	reqs := make([]*http.Request, len(routes))
+	for i, tr := range routes {
+		r, err := route.New(tr.method, tr.path)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		rt.HandleRouteFunc(r, handleOK)
+		reqs[i] = httptest.NewRequest(tr.method, tr.testPath, nil)
+		reqs[i].Header.Set("X-Platform-User-ID", "jnichols")
+	}
+	return rt, reqs
+}
+
+// Just to check!
+func TestMockBoardsScaled(t *testing.T) {
+	rt, reqs := scaledRouter(t, scaledRoutes(1000))
+	for _, req := range reqs {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatal(req.Method, req.URL.Path, w.Code)
+		}
+	}
+}
+
+func benchmarkMockBoards(b *testing.B, n int) {
+	rt, reqs := scaledRouter(b, scaledRoutes(n))
+	w := &mockResponseWriter{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rt.ServeHTTP(w, reqs[i%len(reqs)])
+	}
+}
+
+func BenchmarkMockBoards1k(b *testing.B) {
+	benchmarkMockBoards(b, 1000)
+}
+
+func BenchmarkMockBoards10k(b *testing.B) {
+	benchmarkMockBoards(b, 10000)
+}
+
+/*
+ * ===== Gateway =====
+ *
+ * Use Case: an API gateway in front of MockBoards' internal services loads thousands of routes at startup. Each
+ * service has:
+ *
+ * - 5 routes under a long static prefix, /api/v1/internal/admin/<service>
+ * - 3 routes with a resource ID param
+ *
+ * ===== Using This Benchmark =====
+ *
+ * Run the build benchmark for the memory and allocations of loading the routes, and the gateway benchmark for
+ * the latency of serving them.
+ */
+
+// gatewayRoutes gets n routes for gateway services.
+func gatewayRoutes(n int) []benchRoute {
+	routes := make([]benchRoute, 0, n)
+	for i := 0; len(routes) < n; i++ {
+		svc := fmt.Sprintf("/api/v1/internal/admin/service%d", i)
+		routes = append(routes,
+			benchRoute{method: http.MethodGet, path: svc + "/health/live", testPath: svc + "/health/live"},
+			benchRoute{method: http.MethodGet, path: svc + "/health/ready", testPath: svc + "/health/ready"},
+			benchRoute{method: http.MethodGet, path: svc + "/config/current", testPath: svc + "/config/current"},
+			benchRoute{method: http.MethodGet, path: svc + "/config/defaults", testPath: svc + "/config/defaults"},
+			benchRoute{method: http.MethodPut, path: svc + "/config/current", testPath: svc + "/config/current"},
+			benchRoute{method: http.MethodGet, path: svc + "/resources/[id]", testPath: svc + "/resources/2719"},
+			benchRoute{method: http.MethodDelete, path: svc + "/resources/[id]", testPath: svc + "/resources/2719"},
+			benchRoute{method: http.MethodGet, path: svc + "/resources/[id]/history/latest", testPath: svc + "/resources/2719/history/latest"},
+		)
+	}
+	return routes[:n]
+}
+
+// Just to check!
+func TestGateway(t *testing.T) {
+	rt, reqs := scaledRouter(t, gatewayRoutes(8000))
+	for _, req := range reqs {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatal(req.Method, req.URL.Path, w.Code)
+		}
+	}
+}
+
+func BenchmarkGatewayBuild8k(b *testing.B) {
+	routes := gatewayRoutes(8000)
+	rs := make([]route.Route, len(routes))
+	for i, tr := range routes {
+		rs[i] = route.Declare(tr.method, tr.path)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rt := router.Default()
+		for _, r := range rs {
+			rt.HandleRouteFunc(r, handleOK)
+		}
+	}
+}
+
+func BenchmarkGatewayBuildStrict2k(b *testing.B) {
+	routes := gatewayRoutes(2000)
+	rs := make([]route.Route, len(routes))
+	for i, tr := range routes {
+		rs[i] = route.Declare(tr.method, tr.path)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rt := router.Declare(router.Default(), router.Strict())
+		for _, r := range rs {
+			rt.HandleRouteFunc(r, handleOK)
+		}
+	}
+}
+
+func BenchmarkGateway8k(b *testing.B) {
+	rt, reqs := scaledRouter(b, gatewayRoutes(8000))
+	w := &mockResponseWriter{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rt.ServeHTTP(w, reqs[i%len(reqs)])
+	}
+}
+
+// benchmarkGatewayHealth serves the gateway's health checks, with a match cache of size entries.
+func benchmarkGatewayHealth(b *testing.B, size int) {
+	rt, health := gatewayHealth(b, size)
+	w := &mockResponseWriter{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rt.ServeHTTP(w, health[i%len(health)])
+	}
+}
+
+// gatewayHealth gets the gateway's router, with a match cache of size entries, and its health check requests.
+func gatewayHealth(b *testing.B, size int) (router.Router, []*http.Request) {
+	rt, reqs := scaledRouter(b, gatewayRoutes(8000))
+	if err := router.WithMatchCache(size)(rt); err != nil {
+		b.Fatal(err)
+	}
+	health := make([]*http.Request, 0)
+	for _, req := range reqs {
+		if strings.Contains(req.URL.Path, "/health/") {
+			health = append(health, req)
+		}
+	}
+	return rt, health
+}
+
+func BenchmarkGatewayHealth8k(b *testing.B) {
+	benchmarkGatewayHealth(b, 0)
+}
+
+func BenchmarkGatewayHealthCached8k(b *testing.B) {
+	benchmarkGatewayHealth(b, 4096)
+}
+
+// Health checks usually arrive from many load balancers at once, so cache hits shouldn't contend with each other.
+func BenchmarkGatewayHealthCachedParallel8k(b *testing.B) {
+	rt, health := gatewayHealth(b, 4096)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		w := &mockResponseWriter{}
+		for i := 0; pb.Next(); i++ {
+			rt.ServeHTTP(w, health[i%len(health)])
+		}
+	})
+}
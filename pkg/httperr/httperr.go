@@ -0,0 +1,106 @@
+// Package httperr defines errors that map to HTTP responses, and how Matcha writes them.
+//
+// See [https://github.com/decentplatforms/matcha/blob/main/docs/user-guide.md#error-handling].
+package httperr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// HTTPError is an error with the status code and message to respond with.
+type HTTPError struct {
+	// The status code of the response.
+	Code int
+	// The body of the response. If empty, the status text of Code is used.
+	Message string
+	// The underlying error, if any. It isn't written in the response.
+	Err error
+}
+
+// Create an HTTPError with a status code and message.
+func New(code int, message string) *HTTPError {
+	return &HTTPError{Code: code, Message: message}
+}
+
+// Create an HTTPError with a status code and message for an underlying error.
+func Wrap(code int, message string, err error) *HTTPError {
+	return &HTTPError{Code: code, Message: message, Err: err}
+}
+
+// Get the message of the error, or the status text of its code if the message is empty.
+func (e *HTTPError) Text() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return http.StatusText(e.Code)
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Text() + ": " + e.Err.Error()
+	}
+	return e.Text()
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// Get the status code for an error: the code of the first HTTPError in its chain, or 500 if there isn't one.
+func StatusCode(err error) int {
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return he.Code
+	}
+	return http.StatusInternalServerError
+}
+
+// An ErrorHandler writes the response for an error returned while serving a request.
+type ErrorHandler func(w http.ResponseWriter, req *http.Request, err error)
+
+// Write the response for an error.
+// HTTPErrors found with errors.As are written with their code and text; other errors are written as 500 Internal
+// Server Error, without their message, so internal details aren't exposed.
+func Write(w http.ResponseWriter, req *http.Request, err error) {
+	var he *HTTPError
+	if !errors.As(err, &he) {
+		he = New(http.StatusInternalServerError, "")
+	}
+	w.WriteHeader(he.Code)
+	w.Write([]byte(he.Text()))
+}
+
+// A HandlerFunc is an http.Handler that can return an error.
+// Returned errors are reported with Report.
+type HandlerFunc func(w http.ResponseWriter, req *http.Request) error
+
+// Implements http.Handler.
+func (h HandlerFunc) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := h(w, req); err != nil {
+		Report(w, req, err)
+	}
+}
+
+// handlerKey is the context key for the ErrorHandler of a request.
+type handlerKey struct{}
+
+// Set the ErrorHandler used to report errors for requests with ctx.
+func WithHandler(ctx context.Context, h ErrorHandler) context.Context {
+	return context.WithValue(ctx, handlerKey{}, h)
+}
+
+// Get the ErrorHandler used to report errors for requests with ctx, or Write if none is set.
+func HandlerFrom(ctx context.Context) ErrorHandler {
+	if h, ok := ctx.Value(handlerKey{}).(ErrorHandler); ok && h != nil {
+		return h
+	}
+	return Write
+}
+
+// Report an error returned while serving a request, using the ErrorHandler set on the request context.
+// If no ErrorHandler is set, the error is written with Write.
+func Report(w http.ResponseWriter, req *http.Request, err error) {
+	HandlerFrom(req.Context())(w, req, err)
+}
@@ -0,0 +1,71 @@
+package httperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPError(t *testing.T) {
+	cause := errors.New("no rows")
+	err := fmt.Errorf("get user: %w", Wrap(http.StatusNotFound, "user not found", cause))
+	if code := StatusCode(err); code != http.StatusNotFound {
+		t.Errorf("expected code 404, got %d", code)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("expected HTTPError to unwrap to its cause")
+	}
+	if msg := err.Error(); msg != "get user: user not found: no rows" {
+		t.Errorf("unexpected message %q", msg)
+	}
+	if text := New(http.StatusTeapot, "").Text(); text != "I'm a teapot" {
+		t.Errorf("expected status text for empty message, got %q", text)
+	}
+	if code := StatusCode(cause); code != http.StatusInternalServerError {
+		t.Errorf("expected code 500 for other errors, got %d", code)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+		body string
+	}{
+		{New(http.StatusBadRequest, "bad input"), http.StatusBadRequest, "bad input"},
+		{fmt.Errorf("wrapped: %w", New(http.StatusConflict, "")), http.StatusConflict, "Conflict"},
+		{errors.New("secret details"), http.StatusInternalServerError, "Internal Server Error"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		Write(w, httptest.NewRequest(http.MethodGet, "/", nil), test.err)
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("%v: expected %d %q, got %d %q", test.err, test.code, test.body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	h := HandlerFunc(func(w http.ResponseWriter, req *http.Request) error {
+		return New(http.StatusForbidden, "forbidden")
+	})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden || w.Body.String() != "forbidden" {
+		t.Errorf("expected default error response, got %d %q", w.Code, w.Body.String())
+	}
+	var reported error
+	req = req.WithContext(WithHandler(req.Context(), func(w http.ResponseWriter, req *http.Request, err error) {
+		reported = err
+		w.WriteHeader(StatusCode(err))
+		fmt.Fprintf(w, `{"error":%q}`, err.Error())
+	}))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if StatusCode(reported) != http.StatusForbidden || w.Body.String() != `{"error":"forbidden"}` {
+		t.Errorf("expected custom error response, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -1,9 +1,9 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
 
+	"github.com/decentplatforms/matcha/pkg/httperr"
 	"github.com/decentplatforms/matcha/pkg/regex"
 )
 
@@ -11,6 +11,7 @@ import (
 // Requests are rejected if the query parameter `name` is not present, or if the value
 // doesn't match the provided patterns. `patts` can be left empty to permit any or no
 // value assigned to `name`. Invalid patterns are silently discarded.
+// Rejected requests are reported as 400 Bad Request with httperr.Report.
 //
 // See package Pattern for more details on pattern construction.
 func ExpectQueryParam(name string, patts ...string) Middleware {
@@ -37,19 +38,15 @@ func ExpectQueryParam(name string, patts ...string) Middleware {
 	}
 	return func(w http.ResponseWriter, r *http.Request) *http.Request {
 		q := r.URL.Query()
-		if !q.Has(name) {
-			fmt.Fprintf(w, "invalid value for query param %s", name)
-			return nil
-		}
-		v := r.URL.Query().Get(name)
-		r.URL.Query()
-		for _, f := range fs {
-			if f(v) {
-				return r
+		if q.Has(name) {
+			v := q.Get(name)
+			for _, f := range fs {
+				if f(v) {
+					return r
+				}
 			}
 		}
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "invalid value for query param %s", name)
+		httperr.Report(w, r, httperr.New(http.StatusBadRequest, "invalid value for query param "+name))
 		return nil
 	}
 }
@@ -59,6 +56,7 @@ func ExpectQueryParam(name string, patts ...string) Middleware {
 // doesn't match the provided patterns, if any. `patts` can be left empty to permit
 // any value assigned to `name`, but headers must have a value to be permitted.
 // Invalid patterns are silently discarded.
+// Rejected requests are reported as 400 Bad Request with httperr.Report.
 //
 // See package Pattern for more details on pattern construction.
 func ExpectHeader(name string, patts ...string) Middleware {
@@ -84,20 +82,14 @@ func ExpectHeader(name string, patts ...string) Middleware {
 		fs = append(fs, f)
 	}
 	return func(w http.ResponseWriter, r *http.Request) *http.Request {
-		h := r.Header
-		v := h.Get(name)
-		if v == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("invalid value for header " + name))
-			return nil
-		}
-		for _, f := range fs {
-			if f(v) {
-				return r
+		if v := r.Header.Get(name); v != "" {
+			for _, f := range fs {
+				if f(v) {
+					return r
+				}
 			}
 		}
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("invalid value for header " + name))
+		httperr.Report(w, r, httperr.New(http.StatusBadRequest, "invalid value for header "+name))
 		return nil
 	}
 }
@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/decentplatforms/matcha/pkg/httperr"
 )
 
 func TestExpectQueryParam(t *testing.T) {
@@ -137,3 +140,23 @@ func TestExpectHeader(t *testing.T) {
 		}
 	})
 }
+
+func TestExpectErrors(t *testing.T) {
+	m := ExpectQueryParam("foo")
+	r := httptest.NewRequest("GET", "http://example.com", nil)
+	w := httptest.NewRecorder()
+	ExecuteMiddleware([]Middleware{m}, w, r)
+	if w.Code != http.StatusBadRequest || w.Body.String() != "invalid value for query param foo" {
+		t.Errorf("expected default error response, got %d %q", w.Code, w.Body.String())
+	}
+	var reported error
+	m = ExpectHeader("foo")
+	r = httptest.NewRequest("GET", "http://example.com", nil)
+	r = r.WithContext(httperr.WithHandler(r.Context(), func(w http.ResponseWriter, req *http.Request, err error) {
+		reported = err
+	}))
+	ExecuteMiddleware([]Middleware{m}, httptest.NewRecorder(), r)
+	if httperr.StatusCode(reported) != http.StatusBadRequest || reported.Error() != "invalid value for header foo" {
+		t.Errorf("expected error to be reported, got %v", reported)
+	}
+}
@@ -2,38 +2,98 @@ package middleware
 
 import (
 	"net/http"
+	"net/url"
 	"strings"
+
+	"github.com/decentplatforms/matcha/pkg/httperr"
+	"github.com/decentplatforms/matcha/pkg/path"
+	"github.com/decentplatforms/matcha/pkg/rctx"
 )
 
+// hasPrefix checks if a path starts with a prefix that ends on a segment boundary.
+// For example, /api is a prefix of /api and /api/users, but not /apiary.
+func hasPrefix(p, prefix string) bool {
+	if !strings.HasPrefix(p, prefix) {
+		return false
+	}
+	return len(p) == len(prefix) || strings.HasSuffix(prefix, "/") || p[len(prefix)] == '/'
+}
+
+// segments counts the segments in a path, treating consecutive slashes as one.
+func segments(p string) int {
+	n := 0
+	for _, seg := range strings.Split(p, "/") {
+		if seg != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// strip gets a copy of a request with its path replaced by the rest of the path after a prefix, like
+// http.StripPrefix, so the request passed to the middleware isn't changed.
+// RawPath is trimmed the same way if it still encodes the new path, and cleared otherwise. If the request has
+// a rctx.Context, the prefix is recorded on it; see rctx.GetPrefix.
+func strip(r *http.Request, prefix, rest string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	u := new(url.URL)
+	*u = *r.URL
+	r2.URL = u
+	if u.RawPath != "" {
+		_, rawRest, _ := path.SplitSegments(u.RawPath, segments(prefix))
+		if unescaped, err := url.PathUnescape(rawRest); err == nil && unescaped == rest {
+			u.RawPath = rawRest
+		} else {
+			u.RawPath = ""
+		}
+	}
+	u.Path = rest
+	rctx.SetPrefix(r.Context(), prefix)
+	return r2
+}
+
 // TrimPrefix trims a static prefix from the path of an inbound request.
+// The prefix must end on a segment boundary, so /api is trimmed from /api/users but not /apiary.
 // If the prefix doesn't exist, the request is unmodified. If you want to reject requests
 // without the prefix, use TrimPrefixStrict.
 func TrimPrefix(prefix string) Middleware {
 	return func(w http.ResponseWriter, r *http.Request) *http.Request {
-		path := r.URL.Path
-		if strings.Index(path, prefix) == 0 {
-			r.URL.Path = path[len(prefix):]
+		p := r.URL.Path
+		if hasPrefix(p, prefix) {
+			return strip(r, prefix, p[len(prefix):])
 		}
 		return r
 	}
 }
 
 // TrimPrefixStrict trims a static prefix from the path of an inbound request.
-// If the prefix doesn't exist, the request is rejected and the errMsg is sent as a response.
+// The prefix must end on a segment boundary, as with TrimPrefix.
+// If the prefix doesn't exist, the request is rejected, and reported as 400 Bad Request with errMsg using
+// httperr.Report.
 // An empty errMsg will generate an error message "expected path prefix [prefix]".
 func TrimPrefixStrict(prefix string, errMsg string) Middleware {
 	return func(w http.ResponseWriter, r *http.Request) *http.Request {
-		path := r.URL.Path
-		if strings.Index(path, prefix) == 0 {
-			r.URL.Path = path[len(prefix):]
-		} else {
-			w.WriteHeader(http.StatusBadRequest)
+		p := r.URL.Path
+		if !hasPrefix(p, prefix) {
 			if errMsg == "" {
 				errMsg = "expected path prefix " + prefix
 			}
-			w.Write([]byte(errMsg))
+			httperr.Report(w, r, httperr.New(http.StatusBadRequest, errMsg))
 			return nil
 		}
+		return strip(r, prefix, p[len(prefix):])
+	}
+}
+
+// TrimSegments trims the first n segments from the path of an inbound request, whatever they contain.
+// Consecutive slashes count as a single slash, as they do when matching routes. If the path has fewer than n
+// segments, the request is unmodified.
+func TrimSegments(n int) Middleware {
+	return func(w http.ResponseWriter, r *http.Request) *http.Request {
+		if prefix, rest, ok := path.SplitSegments(r.URL.Path, n); ok {
+			return strip(r, prefix, rest)
+		}
 		return r
 	}
 }
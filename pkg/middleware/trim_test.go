@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/decentplatforms/matcha/pkg/rctx"
 )
 
 func TestTrimPrefix(t *testing.T) {
@@ -36,3 +38,88 @@ func TestTrimPrefix(t *testing.T) {
 		t.Error(http.StatusBadRequest, w.Code)
 	}
 }
+
+func TestTrimSegments(t *testing.T) {
+	w := httptest.NewRecorder()
+	tests := []struct {
+		n    int
+		path string
+		out  string
+	}{
+		{0, "/orgs/decent/projects/matcha", "/orgs/decent/projects/matcha"},
+		{3, "/orgs/decent/projects/matcha", "/matcha"},
+		{3, "/orgs//decent/projects/matcha/", "/matcha/"},
+		{3, "/orgs/decent/projects", ""},
+		{3, "/orgs/decent/projects/", "/"},
+		{3, "/orgs/decent", "/orgs/decent"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if out := TrimSegments(test.n)(w, req).URL.Path; out != test.out {
+			t.Errorf("TrimSegments(%d) on %s: expected %q, got %q", test.n, test.path, test.out, out)
+		}
+	}
+}
+
+func TestTrimBoundary(t *testing.T) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/apiary/hive", nil)
+	if r := TrimPrefix("/api")(w, req); r.URL.Path != "/apiary/hive" {
+		t.Error("/apiary/hive", r.URL.Path)
+	}
+	if r := TrimPrefixStrict("/api", "")(w, req); r != nil {
+		t.Error("expected nil request")
+	}
+	req = httptest.NewRequest(http.MethodGet, "/api", nil)
+	if r := TrimPrefix("/api")(w, req); r.URL.Path != "" {
+		t.Error("", r.URL.Path)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/api/", nil)
+	if r := TrimPrefix("/api/")(w, req); r.URL.Path != "" {
+		t.Error("", r.URL.Path)
+	}
+}
+
+func TestTrimRawPath(t *testing.T) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/my%20files/a%2Fb/c", nil)
+	if req.URL.RawPath == "" {
+		t.Fatal("expected request to have RawPath")
+	}
+	req = rctx.PrepareRequestContext(req, rctx.DefaultMaxParams)
+	r := TrimPrefix("/my files")(w, req)
+	if r.URL.Path != "/a/b/c" || r.URL.RawPath != "/a%2Fb/c" || r.URL.EscapedPath() != "/a%2Fb/c" {
+		t.Errorf("expected /a/b/c with RawPath /a%%2Fb/c, got %s and %s", r.URL.Path, r.URL.RawPath)
+	}
+	if prefix := rctx.GetPrefix(r.Context()); prefix != "/my files" {
+		t.Errorf("expected prefix /my files, got %s", prefix)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/orgs/a%20b/projects/x%2Fy", nil)
+	req = rctx.PrepareRequestContext(req, rctx.DefaultMaxParams)
+	r = TrimSegments(2)(w, req)
+	if r.URL.Path != "/projects/x/y" || r.URL.RawPath != "/projects/x%2Fy" {
+		t.Errorf("expected /projects/x/y with RawPath /projects/x%%2Fy, got %s and %s", r.URL.Path, r.URL.RawPath)
+	}
+
+	// Paths where the segments don't line up clear RawPath, so it's never inconsistent.
+	req = httptest.NewRequest(http.MethodGet, "/a%2Fb/c%2Fd", nil)
+	r = TrimPrefix("/a/b")(w, req)
+	if r.URL.Path != "/c/d" || r.URL.RawPath != "" {
+		t.Errorf("expected /c/d with no RawPath, got %s and %s", r.URL.Path, r.URL.RawPath)
+	}
+}
+
+func TestTrimCopy(t *testing.T) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/a%2Fb", nil)
+	for _, mw := range []Middleware{TrimPrefix("/api"), TrimPrefixStrict("/api", ""), TrimSegments(1)} {
+		r := mw(w, req)
+		if r == req || r.URL == req.URL {
+			t.Error("expected trimmed request to be a copy")
+		}
+		if r.URL.Path != "/a/b" || req.URL.Path != "/api/a/b" || req.URL.RawPath != "/api/a%2Fb" {
+			t.Errorf("expected only the copy to be trimmed, got %s and %s", r.URL.Path, req.URL.Path)
+		}
+	}
+}
@@ -2,6 +2,7 @@
 package path
 
 import (
+	gopath "path"
 	"strings"
 )
 
@@ -37,6 +38,9 @@ func MakePartial(path string, param string) string {
 	if param != "" {
 		param = "[" + param + "]"
 	}
+	if path == "" || path == "/" {
+		return "/" + param + "+"
+	}
 	i := len(path) - 1
 	if path[i-1:] == "/+" {
 		path = path[:i-1]
@@ -45,3 +49,58 @@ func MakePartial(path string, param string) string {
 	}
 	return path + "/" + param + "+"
 }
+
+// SplitSegments splits a path after its first n segments, treating consecutive slashes as one, like Next.
+// Returns ok == false if the path has fewer than n segments.
+func SplitSegments(path string, n int) (prefix, rest string, ok bool) {
+	next := 0
+	for i := 0; i < n; i++ {
+		if next == -1 {
+			return path, "", false
+		}
+		_, next = Next(path, next)
+	}
+	if next == -1 {
+		return path, "", true
+	}
+	return path[:next], path[next:], true
+}
+
+// Join joins a prefix onto a path.
+// Trailing slashes on the prefix are dropped, and joining the root path "/" gives the prefix itself.
+func Join(prefix, path string) string {
+	prefix = strings.TrimRight(prefix, "/")
+	if path == "" || path == "/" {
+		if prefix == "" {
+			return "/"
+		}
+		return prefix
+	}
+	if path[0] != '/' {
+		path = "/" + path
+	}
+	return prefix + path
+}
+
+// isClean checks if a path is already in the form returned by Clean.
+func isClean(path string) bool {
+	if path == "" || path[0] != '/' {
+		return false
+	}
+	return !strings.Contains(path, "//") && !strings.Contains(path, "/./") && !strings.Contains(path, "/../") &&
+		!strings.HasSuffix(path, "/.") && !strings.HasSuffix(path, "/..")
+}
+
+// Clean gives the canonical form of a path: consecutive slashes are replaced by a single slash, and dot
+// segments are resolved, as with path.Clean from the standard library. Unlike path.Clean, a trailing slash is
+// kept, and the empty path cleans to "/".
+func Clean(path string) string {
+	if isClean(path) {
+		return path
+	}
+	cleaned := gopath.Clean("/" + path)
+	if cleaned != "/" && (strings.HasSuffix(path, "/") || strings.HasSuffix(path, "/.") || strings.HasSuffix(path, "/..")) {
+		cleaned += "/"
+	}
+	return cleaned
+}
@@ -63,4 +63,75 @@ func TestMakePartial(t *testing.T) {
 	if px := MakePartial("/hello", "next"); px != "/hello/[next]+" {
 		t.Error("/hello/[next]+", px)
 	}
+	if px := MakePartial("/", ""); px != "/+" {
+		t.Error("/+", px)
+	}
+	if px := MakePartial("", "next"); px != "/[next]+" {
+		t.Error("/[next]+", px)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	tests := [][3]string{
+		{"/api", "/users", "/api/users"},
+		{"/api/", "/users", "/api/users"},
+		{"/api", "users", "/api/users"},
+		{"/api", "/", "/api"},
+		{"", "/users", "/users"},
+		{"/", "/", "/"},
+		{"", "", "/"},
+	}
+	for _, test := range tests {
+		if joined := Join(test[0], test[1]); joined != test[2] {
+			t.Errorf("Join(%q, %q): expected %s, got %s", test[0], test[1], test[2], joined)
+		}
+	}
+}
+
+func TestSplitSegments(t *testing.T) {
+	tests := []struct {
+		path   string
+		n      int
+		prefix string
+		rest   string
+		ok     bool
+	}{
+		{"/a/b/c", 0, "", "/a/b/c", true},
+		{"/a/b/c", 2, "/a/b", "/c", true},
+		{"/a//b/c/", 2, "/a//b", "/c/", true},
+		{"/a/b", 2, "/a/b", "", true},
+		{"/a/b/", 2, "/a/b", "/", true},
+		{"/a", 2, "/a", "", false},
+	}
+	for _, test := range tests {
+		prefix, rest, ok := SplitSegments(test.path, test.n)
+		if prefix != test.prefix || rest != test.rest || ok != test.ok {
+			t.Errorf("SplitSegments(%q, %d): expected %q %q %v, got %q %q %v", test.path, test.n, test.prefix, test.rest, test.ok, prefix, rest, ok)
+		}
+	}
+}
+
+func TestClean(t *testing.T) {
+	tests := [][2]string{
+		{"/users", "/users"},
+		{"/users/", "/users/"},
+		{"", "/"},
+		{"/", "/"},
+		{"//users", "/users"},
+		{"/users//", "/users/"},
+		{"/a//b///c", "/a/b/c"},
+		{"/a/./b", "/a/b"},
+		{"/a/../users", "/users"},
+		{"/a/b/..", "/a/"},
+		{"/a/.", "/a/"},
+		{"/..", "/"},
+		{"/../a", "/a"},
+		{"users", "/users"},
+		{"/.well-known/x", "/.well-known/x"},
+	}
+	for _, test := range tests {
+		if cleaned := Clean(test[0]); cleaned != test[1] {
+			t.Errorf("Clean(%q): expected %s, got %s", test[0], test[1], cleaned)
+		}
+	}
 }
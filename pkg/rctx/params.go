@@ -2,8 +2,23 @@ package rctx
 
 import (
 	"errors"
+	"fmt"
+	"sync/atomic"
 )
 
+// ErrOverCapacity is returned by SetParam when a param can't be set because the context is at capacity.
+var ErrOverCapacity = errors.New("params over capacity")
+
+// dropped counts the params that couldn't be set because their context was at capacity.
+var dropped atomic.Uint64
+
+// Get the number of params that couldn't be set because their context was at capacity, across all contexts.
+// Routers size contexts to fit the params of the matched route, so params are only dropped when middleware or
+// handlers set more params than the context can hold.
+func DroppedParams() uint64 {
+	return dropped.Load()
+}
+
 type paramKey string
 
 type routeParam struct {
@@ -15,6 +30,18 @@ type routeParams struct {
 	rps  []routeParam
 	cap  int
 	head int
+	// grow lets the params grow past cap instead of failing.
+	grow bool
+	// replaced holds the values replaced by set while marking, in order, so ResetParams can restore them.
+	replaced []replacedParam
+	// marking is set by MarkParams, and cleared by ClearParamMarks once a router is done binding params.
+	marking bool
+}
+
+// replacedParam is the value a param had before set replaced it.
+type replacedParam struct {
+	idx   int
+	value string
 }
 
 // PARAMETERS
@@ -52,12 +79,36 @@ func (rps *routeParams) set(key paramKey, value string) error {
 		}
 	}
 	if idx >= rps.cap {
-		return errors.New("over capacity")
+		if !rps.grow {
+			dropped.Add(1)
+			return fmt.Errorf("set param %s: %w", key, ErrOverCapacity)
+		}
+		if idx >= len(rps.rps) {
+			rps.rps = append(rps.rps, routeParam{})
+		}
+		rps.cap++
 	}
 	if inc {
 		rps.head++
+	} else if rps.marking {
+		rps.replaced = append(rps.replaced, replacedParam{idx, rps.rps[idx].value})
 	}
 	rps.rps[idx].key = key
 	rps.rps[idx].value = value
 	return nil
 }
+
+// reset removes every param, along with the values they replaced.
+func (rps *routeParams) reset() {
+	rps.head = 0
+	rps.clearReplaced()
+}
+
+// clearReplaced stops recording replaced values, and drops the values recorded so far.
+func (rps *routeParams) clearReplaced() {
+	rps.marking = false
+	for i := range rps.replaced {
+		rps.replaced[i].value = ""
+	}
+	rps.replaced = rps.replaced[:0]
+}
@@ -15,9 +15,13 @@ const (
 	DefaultMaxParams = int(10)
 )
 
+// contextKey gets the closest *rctx.Context from a context that wraps it.
+type contextKey struct{}
+
 type Context struct {
 	parent context.Context
 	params *routeParams
+	prefix string
 	err    error
 }
 
@@ -30,19 +34,32 @@ var rctxPool = &sync.Pool{
 func new(parent context.Context, maxParams int) *Context {
 	rctx := rctxPool.Get().(*Context)
 	rctx.parent = parent
-	if rctx.params == nil || rctx.params.cap < maxParams {
+	if rctx.params == nil || len(rctx.params.rps) < maxParams {
 		rctx.params = newParams(maxParams)
 	} else {
 		rctx.params.cap = maxParams
-		rctx.params.head = 0
+		rctx.params.reset()
+		rctx.params.grow = false
 	}
 	return rctx
 }
 
 // PrepareRequestContext prepares the context of a request for matching.
 func PrepareRequestContext(req *http.Request, maxParams int) *http.Request {
-	rctx := new(req.Context(), maxParams)
-	return req.WithContext(rctx)
+	return Attach(req, new(nil, maxParams))
+}
+
+// Acquire gets a context with space for maxParams params that isn't attached to a request yet.
+// Routers use it to set params while matching a request, before they know which route, if any, will serve it.
+// The context must be attached to the request with Attach, or returned to the pool with Release.
+func Acquire(maxParams int) *Context {
+	return new(nil, maxParams)
+}
+
+// Attach makes the context of a request the parent of ctx, and returns the request with ctx as its context.
+func Attach(req *http.Request, ctx *Context) *http.Request {
+	ctx.parent = req.Context()
+	return req.WithContext(ctx)
 }
 
 // ResetRequestContext resets any values in the context that shouldn't be maintained between attempts to match routes.
@@ -53,20 +70,27 @@ func ResetRequestContext(req *http.Request) error {
 	if !correctType {
 		return errors.New("request must have *rctx.Context when resetting")
 	}
-	rctx.params.head = 0
+	rctx.params.reset()
 	return nil
 }
 
 func ReturnRequestContext(req *http.Request) {
 	if rctx, ok := req.Context().(*Context); ok {
-		rctx.parent = nil
-		rctx.err = nil
-		for i := range rctx.params.rps {
-			rctx.params.rps[i].key = ""
-			rctx.params.rps[i].value = ""
-		}
-		rctxPool.Put(rctx)
+		Release(rctx)
+	}
+}
+
+// Release returns a context to the pool. The context must not be used afterward.
+func Release(rctx *Context) {
+	rctx.parent = nil
+	rctx.prefix = ""
+	rctx.err = nil
+	for i := range rctx.params.rps {
+		rctx.params.rps[i].key = ""
+		rctx.params.rps[i].value = ""
 	}
+	rctx.params.reset()
+	rctxPool.Put(rctx)
 }
 
 // PARAMETER IMPLEMENTATION
@@ -95,8 +119,8 @@ func GetParam(ctx context.Context, key string) string {
 
 // SetParam sets a parameter with a key string.
 // This automatically converts key to its underlying context key type.
-// Context params have a max value determined at creation, and this returns an error if the user attempts to exceed
-// the maximum number of params.
+// Context params have a max value determined at creation, and this returns an error wrapping ErrOverCapacity if
+// the user attempts to exceed the maximum number of params, unless the context can grow; see GrowParams.
 func SetParam(ctx context.Context, key, value string) error {
 	if rctx, ok := ctx.(*Context); ok {
 		return rctx.params.set(paramKey(key), value)
@@ -104,10 +128,121 @@ func SetParam(ctx context.Context, key, value string) error {
 	return errors.New("cannot SetParam on non-rctx Context")
 }
 
+// GrowParams lets the params of a context grow past the capacity it was created with, instead of failing to
+// set params once the context is full.
+func GrowParams(ctx context.Context) error {
+	if rctx, ok := ctx.(*Context); ok {
+		rctx.params.grow = true
+		return nil
+	}
+	return errors.New("cannot GrowParams on non-rctx Context")
+}
+
+// SetParamCapacity sets the number of params a context can hold, without removing params it already has.
+// Routers bind params with enough capacity for any of their routes, then size the context for the route that
+// matched.
+func SetParamCapacity(ctx context.Context, n int) error {
+	if rctx, ok := ctx.(*Context); ok {
+		if n < rctx.params.head {
+			n = rctx.params.head
+		}
+		for len(rctx.params.rps) < n {
+			rctx.params.rps = append(rctx.params.rps, routeParam{})
+		}
+		rctx.params.cap = n
+		return nil
+	}
+	return errors.New("cannot SetParamCapacity on non-rctx Context")
+}
+
+// ParamCount gets the number of params set on a context.
+// Returns 0 for contexts that aren't *rctx.Context.
+func ParamCount(ctx context.Context) int {
+	if rctx, ok := ctx.(*Context); ok {
+		return rctx.params.head
+	}
+	return 0
+}
+
+// A ParamMark records the params set on a context at some point, so they can be restored with ResetParams.
+type ParamMark struct {
+	head     int
+	replaced int
+}
+
+// MarkParams records the params set on a context, so a router can restore them with ResetParams if a route turns
+// out not to match after setting params of its own. Until ClearParamMarks is called, the context records every
+// param value that's replaced, so routers should call it once they're done binding params.
+// Returns an empty ParamMark for contexts that aren't *rctx.Context.
+func MarkParams(ctx context.Context) ParamMark {
+	if rctx, ok := ctx.(*Context); ok {
+		rctx.params.marking = true
+		return ParamMark{head: rctx.params.head, replaced: len(rctx.params.replaced)}
+	}
+	return ParamMark{}
+}
+
+// ClearParamMarks stops recording replaced param values for ResetParams, so params replaced by middleware and
+// handlers aren't recorded. Marks taken before can no longer restore replaced values.
+func ClearParamMarks(ctx context.Context) error {
+	if rctx, ok := ctx.(*Context); ok {
+		rctx.params.clearReplaced()
+		return nil
+	}
+	return errors.New("cannot ClearParamMarks on non-rctx Context")
+}
+
+// ResetParams restores the params of a context to the way they were when mark was taken: params set since are
+// removed, and params replaced since get their earlier values back.
+func ResetParams(ctx context.Context, mark ParamMark) error {
+	if rctx, ok := ctx.(*Context); ok {
+		rps := rctx.params
+		if mark.replaced < len(rps.replaced) {
+			for i := len(rps.replaced) - 1; i >= mark.replaced; i-- {
+				rps.rps[rps.replaced[i].idx].value = rps.replaced[i].value
+				rps.replaced[i].value = ""
+			}
+			rps.replaced = rps.replaced[:mark.replaced]
+		}
+		if mark.head < rps.head {
+			rps.head = mark.head
+		}
+		return nil
+	}
+	return errors.New("cannot ResetParams on non-rctx Context")
+}
+
+// PREFIX IMPLEMENTATION
+
+// GetPrefix gets the path prefix stripped from the request by mounts.
+// Prefixes stripped by mounts in parent contexts come first, so the result is the full prefix stripped from the
+// original request path.
+func GetPrefix(ctx context.Context) string {
+	rctx, ok := ctx.(*Context)
+	if !ok {
+		if rctx, ok = ctx.Value(contextKey{}).(*Context); !ok {
+			return ""
+		}
+	}
+	if rctx.parent != nil {
+		return GetPrefix(rctx.parent) + rctx.prefix
+	}
+	return rctx.prefix
+}
+
+// SetPrefix sets the path prefix stripped from the request by a mount.
+func SetPrefix(ctx context.Context, prefix string) error {
+	if rctx, ok := ctx.(*Context); ok {
+		rctx.prefix = prefix
+		return nil
+	}
+	return errors.New("cannot SetPrefix on non-rctx Context")
+}
+
 // CONTEXT IMPLEMENTATION
 
 // rctx.Context does not natively support deadlines.
-// If the parent context has a deadline, that will be returned.
+// If the parent context has a deadline, like the one set by routes with timeouts, that will be returned.
 //
 // See interface context.Context.
 func (ctx *Context) Deadline() (time.Time, bool) {
@@ -144,6 +279,9 @@ func (ctx *Context) Err() error {
 //
 // See interface context.Context.
 func (ctx *Context) Value(key any) any {
+	if _, ok := key.(contextKey); ok {
+		return ctx
+	}
 	if pkey, ok := key.(paramKey); ok {
 		v := ctx.params.get(pkey)
 		if v != "" {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -252,3 +253,166 @@ func TestNested(t *testing.T) {
 		t.Error("", p1)
 	}
 }
+
+type prefixTestKey struct{}
+
+func TestPrefix(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/orgs/decent/projects/matcha", nil)
+	if prefix := GetPrefix(req.Context()); prefix != "" {
+		t.Errorf("expected no prefix, got %q", prefix)
+	}
+	if err := SetPrefix(req.Context(), "/orgs"); err == nil {
+		t.Error("expected error setting prefix on non-rctx Context")
+	}
+	outer := PrepareRequestContext(req, DefaultMaxParams)
+	SetPrefix(outer.Context(), "/orgs/decent")
+	// Contexts wrapping an rctx.Context still get its prefix.
+	wrapped := outer.WithContext(context.WithValue(outer.Context(), prefixTestKey{}, "value"))
+	inner := PrepareRequestContext(wrapped, DefaultMaxParams)
+	if prefix := GetPrefix(inner.Context()); prefix != "/orgs/decent" {
+		t.Errorf("expected /orgs/decent, got %q", prefix)
+	}
+	SetPrefix(inner.Context(), "/projects")
+	if prefix := GetPrefix(inner.Context()); prefix != "/orgs/decent/projects" {
+		t.Errorf("expected /orgs/decent/projects, got %q", prefix)
+	}
+	ReturnRequestContext(inner)
+	ReturnRequestContext(outer)
+}
+
+func TestParamCapacity(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = PrepareRequestContext(req, 1)
+	ctx := req.Context()
+	if err := SetParam(ctx, "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	// Setting an existing param doesn't need more capacity.
+	if err := SetParam(ctx, "a", "2"); err != nil {
+		t.Fatal(err)
+	}
+	before := DroppedParams()
+	err := SetParam(ctx, "b", "3")
+	if !errors.Is(err, ErrOverCapacity) || !strings.Contains(err.Error(), "b") {
+		t.Errorf("expected over capacity error naming the param, got %v", err)
+	}
+	if dropped := DroppedParams() - before; dropped != 1 {
+		t.Errorf("expected 1 dropped param, got %d", dropped)
+	}
+	if err := GrowParams(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range []string{"b", "c", "d"} {
+		if err := SetParam(ctx, key, key); err != nil {
+			t.Fatalf("param %d: %s", i, err)
+		}
+	}
+	if GetParam(ctx, "a") != "2" || GetParam(ctx, "d") != "d" {
+		t.Errorf("expected grown params to keep their values, got a=%q d=%q", GetParam(ctx, "a"), GetParam(ctx, "d"))
+	}
+	ReturnRequestContext(req)
+	// Reused contexts don't keep growing.
+	req = PrepareRequestContext(httptest.NewRequest(http.MethodGet, "/", nil), 1)
+	SetParam(req.Context(), "a", "1")
+	if err := SetParam(req.Context(), "b", "2"); !errors.Is(err, ErrOverCapacity) {
+		t.Errorf("expected over capacity error after reuse, got %v", err)
+	}
+	if err := GrowParams(context.Background()); err == nil {
+		t.Error("expected error growing params on non-rctx Context")
+	}
+	ReturnRequestContext(req)
+}
+
+type testKey struct{}
+
+func TestAttach(t *testing.T) {
+	ctx := Acquire(2)
+	if err := SetParam(ctx, "id", "12"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetParam(ctx, "name", "readme"); err != nil {
+		t.Fatal(err)
+	}
+	if n := ParamCount(ctx); n != 2 {
+		t.Errorf("expected 2 params, got %d", n)
+	}
+	// Resetting to a mark removes params set since, and restores params replaced since.
+	mark := MarkParams(ctx)
+	if err := SetParam(ctx, "id", "13"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetParam(ctx, "id", "14"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ResetParams(ctx, mark); err != nil {
+		t.Fatal(err)
+	}
+	if GetParam(ctx, "id") != "12" || GetParam(ctx, "name") != "readme" {
+		t.Errorf("expected replaced param to be restored, got id=%q name=%q", GetParam(ctx, "id"), GetParam(ctx, "name"))
+	}
+	// Once marks are cleared, replaced values aren't recorded.
+	if err := ClearParamMarks(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := SetParam(ctx, "id", "13"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(ctx.params.replaced); n != 0 {
+		t.Errorf("expected no replaced values recorded without a mark, got %d", n)
+	}
+	if err := SetParam(ctx, "id", "12"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ResetParams(ctx, ParamMark{head: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if GetParam(ctx, "id") != "12" || GetParam(ctx, "name") != "" {
+		t.Errorf("expected only the first param after resetting, got id=%q name=%q", GetParam(ctx, "id"), GetParam(ctx, "name"))
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), testKey{}, "parent"))
+	req = Attach(req, ctx)
+	if req.Context() != ctx {
+		t.Error("expected attached context to be the request context")
+	}
+	if v := req.Context().Value(testKey{}); v != "parent" {
+		t.Errorf("expected values from the request's original context, got %v", v)
+	}
+	if GetParam(req.Context(), "id") != "12" {
+		t.Error("expected params set before attaching to be kept")
+	}
+	if err := SetParamCapacity(req.Context(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if GetParam(req.Context(), "id") != "12" {
+		t.Error("expected shrinking capacity to keep params")
+	}
+	if err := SetParam(req.Context(), "name", "readme"); !errors.Is(err, ErrOverCapacity) {
+		t.Errorf("expected over capacity error after shrinking, got %v", err)
+	}
+	if err := SetParamCapacity(req.Context(), 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetParam(req.Context(), "name", "readme"); err != nil {
+		t.Errorf("expected param to fit after growing capacity, got %v", err)
+	}
+	ReturnRequestContext(req)
+	if err := SetParamCapacity(context.Background(), 1); err == nil {
+		t.Error("expected error setting capacity on non-rctx Context")
+	}
+	if n := ParamCount(context.Background()); n != 0 {
+		t.Errorf("expected no params on non-rctx Context, got %d", n)
+	}
+	if mark := MarkParams(context.Background()); mark != (ParamMark{}) {
+		t.Errorf("expected empty mark on non-rctx Context, got %+v", mark)
+	}
+	if err := ResetParams(context.Background(), ParamMark{}); err == nil {
+		t.Error("expected error resetting params on non-rctx Context")
+	}
+	if err := ClearParamMarks(context.Background()); err == nil {
+		t.Error("expected error clearing param marks on non-rctx Context")
+	}
+	Release(Acquire(1))
+}
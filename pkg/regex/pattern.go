@@ -6,94 +6,122 @@ import (
 	"strings"
 )
 
-// findAllRegexGroups finds all regex expressions contained in {}s.
-// These groups are returned in [][]int, an array of int paipatt representing the start
-// and end of the regex groups. Returns an error if unbalanced brackets are found.
-func findAllRegexGroups(expr string) ([][]int, error) {
-	out := make([][]int, 0)
-	start, end := -1, -1
+// Patterns are a combination of static string components and regex validation.
+// Any piece of the string contained in brackets {} will be matched as regex, while any outside of brackets
+// will be matched as itself; for example, {.*}.decentplatforms.{.*} matches any subdomain and top-level domain for
+// decentplatforms. Patterns *must* contain some regex, and match whole strings.
+//
+// Patterns compiled with CompileCapturePattern can also capture named parts of the matched string: a name in
+// square brackets [] captures the characters up to the next "." as a value with that name; for example,
+// [tenant].decentplatforms.com captures "acme" from acme.decentplatforms.com. The captured value can be restricted
+// by following the name with regex, like [tenant]{[a-z]+}.
+//
+// Patterns are compiled to a single regexp.
+type Pattern struct {
+	expr  *regexp.Regexp
+	names []string
+	// groups are the indices of the regexp's subexpressions for names.
+	groups []int
+}
+
+// isCaptureName checks if a string can be used as a capture name.
+func isCaptureName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// closeRegexGroup finds the index of the bracket closing a regex group that starts at start.
+// Returns -1 if the group isn't closed.
+func closeRegexGroup(expr string, start int) int {
 	depth := 0
-	for i, r := range expr {
-		if r == '{' {
-			if depth == 0 {
-				start = i
-			}
+	for i := start; i < len(expr); i++ {
+		switch expr[i] {
+		case '{':
 			depth++
-		}
-		if r == '}' {
+		case '}':
 			depth--
 			if depth == 0 {
-				end = i
+				return i
 			}
 		}
-		if start != -1 && end != -1 {
-			out = append(out, []int{start, end + 1})
-			start, end = -1, -1
-		}
-	}
-	if depth != 0 {
-		return nil, errors.New("unbalanced brackets in pattern " + expr)
 	}
-	return out, nil
-}
-
-// pmf is a pattern match function.
-// pmfs take in a string and start position and output the end position of the token
-// matched, or -1 if no match is found. this allows for easy token length tracking,
-// since regex matches will frequently be of variable length.
-type pmf func(string, int) int
-
-// Patterns are a combination of static string components and regex validation.
-// Any piece of the string contained in brackets {} will be matched as regex, while any outside of brackets
-// will be matched as itself; for example, {.*}.decentplatforms.{.*} matches any subdomain and top-level domain for
-// decentplatforms. Patterns *must* contain some regex. It performs generally equivalent to the exact same regex in
-// brackets (see regex_bench_test.go) and better if static string parts are swapped out of the expression entirely.
-type Pattern struct {
-	fs      []pmf
-	statics []string
+	return -1
 }
 
-// matchf generates a pmf for a given string token.
-// If given a token in {}s, it will generate a pmf that matches against the contained regex; otherwise, it
-// will match against the provided raw string.
-//
-// Returns pmf, isStatic, err, where isStatic represents the choice to use a static string or not; Patterns
-// need this during compilation to store static parts, used to delimit inputs during matching.
-func matchf(tk string) (pmf, bool, error) {
-	if tk[0] == '{' && tk[len(tk)-1] == '}' {
-		expr, err := regexp.Compile(tk[1 : len(tk)-1])
-		if err != nil {
-			return nil, false, err
-		}
-		return func(s string, i int) int {
-			match := expr.FindString(s[i:])
-			if match == "" {
-				return -1
+// compile compiles a pattern expression to a Pattern.
+// If capture is true, names in square brackets capture parts of the matched string; otherwise, square brackets are
+// matched as themselves. If fold is true, the pattern matches case-insensitively.
+func compile(expr string, capture, fold bool) (*Pattern, error) {
+	var sb strings.Builder
+	if fold {
+		sb.WriteString("(?i)")
+	}
+	sb.WriteString("^")
+	names := make([]string, 0)
+	static := 0
+	for i := 0; i < len(expr); {
+		switch c := expr[i]; {
+		case c == '[' && capture:
+			end := strings.IndexByte(expr[i:], ']')
+			if end == -1 {
+				return nil, errors.New("unbalanced brackets in pattern " + expr)
+			}
+			name := expr[i+1 : i+end]
+			if !isCaptureName(name) {
+				return nil, errors.New("invalid capture name [" + name + "] in pattern " + expr)
 			}
-			return i + strings.Index(s[i:], match) + len(match)
-		}, false, nil
-	} else {
-		return func(s string, i int) int {
-			if end := i + len(tk); len(s) < end || tk != s[i:end] {
-				return -1
-			} else {
-				return end
+			for _, other := range names {
+				if other == name {
+					return nil, errors.New("duplicate capture name [" + name + "] in pattern " + expr)
+				}
 			}
-		}, true, nil
+			names = append(names, name)
+			sb.WriteString(regexp.QuoteMeta(expr[static:i]))
+			i += end + 1
+			re := "[^.]+"
+			if i < len(expr) && expr[i] == '{' {
+				rend := closeRegexGroup(expr, i)
+				if rend == -1 {
+					return nil, errors.New("unbalanced brackets in pattern " + expr)
+				}
+				re = expr[i+1 : rend]
+				i = rend + 1
+			}
+			sb.WriteString("(?P<" + name + ">" + re + ")")
+			static = i
+		case c == '{':
+			rend := closeRegexGroup(expr, i)
+			if rend == -1 {
+				return nil, errors.New("unbalanced brackets in pattern " + expr)
+			}
+			sb.WriteString(regexp.QuoteMeta(expr[static:i]))
+			sb.WriteString("(?:" + expr[i+1:rend] + ")")
+			i = rend + 1
+			static = i
+		case c == '}', c == ']' && capture:
+			return nil, errors.New("unbalanced brackets in pattern " + expr)
+		default:
+			i++
+		}
 	}
-}
-
-// resolve adds the pmf for the pattern within the set to the Pattern.
-// This returns an error if the pmf fails to compile.
-func resolve(patt *Pattern, expr string, set []int) error {
-	f, static, err := matchf(expr[set[0]:set[1]])
+	sb.WriteString(regexp.QuoteMeta(expr[static:]))
+	sb.WriteString("$")
+	re, err := regexp.Compile(sb.String())
 	if err != nil {
-		return err
-	} else if static {
-		patt.statics = append(patt.statics, expr[set[0]:set[1]])
+		return nil, err
+	}
+	groups := make([]int, len(names))
+	for i, name := range names {
+		groups[i] = re.SubexpIndex(name)
 	}
-	patt.fs = append(patt.fs, f)
-	return nil
+	return &Pattern{expr: re, names: names, groups: groups}, nil
 }
 
 // CompilePattern compiles a string pattern expression to a *regex.Pattern.
@@ -105,77 +133,48 @@ func CompilePattern(expr string) (*Pattern, bool, error) {
 	if !strings.ContainsAny(expr, "{}") {
 		return nil, false, nil
 	}
-	patt := &Pattern{
-		fs:      make([]pmf, 0),
-		statics: make([]string, 0),
-	}
-	regexIndices, err := findAllRegexGroups(expr)
-	if err != nil || len(regexIndices) == 0 {
-		return nil, false, err
-	}
-	var set, next []int
-	// Start with the first set
-	setidx := 0
-	set = regexIndices[setidx]
-	if len(regexIndices) > 1 {
-		next = regexIndices[1]
-	} else {
-		next = []int{set[1], len(expr)}
-	}
-	for set[1] < len(expr) {
-		// For each set, resolve the token in the set
-		err = resolve(patt, expr, set)
-		if err != nil {
-			return nil, false, err
-		}
-		// Move to the next set OR whatever's in between this set and the next.
-		if set[1] == next[0] && setidx+1 < len(regexIndices) {
-			// Sets line up and the next set exists; go to next
-			setidx++
-			next = regexIndices[setidx]
-		} else if next[0] > set[1] {
-			// Space in between sets; go to whatever's in between
-			next = []int{set[1], next[0]}
-		} else {
-			// Sets line up, but there's no next set; go to the last available substring
-			next = []int{set[1], len(expr)}
-		}
-		set = next
-	}
-	// Resolve the last set.
-	err = resolve(patt, expr, set)
+	patt, err := compile(expr, false, false)
 	if err != nil {
 		return nil, false, err
 	}
 	return patt, true, nil
 }
 
-// findStatic finds the start index of the idx'th static element of the pattern.
-func (patt *Pattern) findStatic(in string, idx int) int {
-	if idx >= len(patt.statics) {
-		return -1
-	}
-	return strings.Index(in, patt.statics[idx])
+// CompileCapturePattern compiles a string pattern expression with capture names to a *regex.Pattern.
+// Unlike CompilePattern, expressions without regex are compiled too, and match themselves.
+// If fold is true, the pattern matches case-insensitively.
+//
+// Returns an error if the expression has unbalanced brackets, invalid names, or invalid regex.
+func CompileCapturePattern(expr string, fold bool) (*Pattern, error) {
+	return compile(expr, true, fold)
+}
+
+// Names gets the capture names of the pattern, in the order they appear.
+func (patt *Pattern) Names() []string {
+	return patt.names
 }
 
 // Match matches a pattern to a string.
 func (patt *Pattern) Match(str string) bool {
-	static := 0
-	i := 0
-	for _, f := range patt.fs {
-		tk := str
-		// Limit tokens to static parts, if they're available. Avoids overconsumption from regex.
-		if max := patt.findStatic(str, static); max != -1 {
-			tk = tk[:max]
-			static++
-		}
-		i = f(tk, i)
-		if i == -1 {
-			return false
+	return patt.expr.MatchString(str)
+}
+
+// Capture matches a pattern to a string, and captures the values of its names.
+// Returns the captured values in the same order as Names, or ok == false if the string doesn't match.
+func (patt *Pattern) Capture(str string) (values []string, ok bool) {
+	if len(patt.names) == 0 {
+		if !patt.expr.MatchString(str) {
+			return nil, false
 		}
+		return []string{}, true
 	}
-	if i != len(str) {
-		return false
+	groups := patt.expr.FindStringSubmatchIndex(str)
+	if groups == nil {
+		return nil, false
 	}
-	return true
+	values = make([]string, len(patt.names))
+	for i, idx := range patt.groups {
+		values[i] = str[groups[2*idx]:groups[2*idx+1]]
+	}
+	return values, true
 }
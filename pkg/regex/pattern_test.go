@@ -1,6 +1,9 @@
 package regex
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestPattern(t *testing.T) {
 	rs, isrs, err := CompilePattern("{(api|www)}.decentplatforms.{.*}")
@@ -60,4 +63,58 @@ func TestPattern(t *testing.T) {
 	if err == nil {
 		t.Errorf("should fail with invalid regex")
 	}
+	// Regex matches the whole string, not just part of it.
+	rs, _, _ = CompilePattern("{[0-9]+}.com")
+	if ok := rs.Match("abc1.com"); ok {
+		t.Error("expected no match")
+	}
+	// Square brackets are static without captures.
+	rs, _, _ = CompilePattern("[x]{.+}")
+	if ok := rs.Match("[x]y"); !ok {
+		t.Error("expected match")
+	}
+	if names := rs.Names(); len(names) != 0 {
+		t.Errorf("expected no names, got %v", names)
+	}
+}
+
+func TestCapturePattern(t *testing.T) {
+	tests := []struct {
+		expr   string
+		fold   bool
+		in     string
+		values []string
+		ok     bool
+	}{
+		{"[tenant].api.decentplatforms.com", false, "acme.api.decentplatforms.com", []string{"acme"}, true},
+		{"[tenant].api.decentplatforms.com", false, "a.b.api.decentplatforms.com", nil, false},
+		{"[tenant].api.decentplatforms.com", false, "acme.apixdecentplatforms.com", nil, false},
+		{"[tenant].api.decentplatforms.com", false, "acme.API.decentplatforms.com", nil, false},
+		{"[tenant].api.decentplatforms.com", true, "acme.API.decentplatforms.com", []string{"acme"}, true},
+		{"[tenant]{[a-z]+}-[env].{(api|www)}.decentplatforms.com", false, "acme-dev.www.decentplatforms.com", []string{"acme", "dev"}, true},
+		{"[tenant]{[a-z]+}-[env].{(api|www)}.decentplatforms.com", false, "acme1-dev.www.decentplatforms.com", nil, false},
+		{"{.+}.decentplatforms.com", false, "www.decentplatforms.com", []string{}, true},
+		{"decentplatforms.com", false, "decentplatforms.com", []string{}, true},
+		{"decentplatforms.com", false, "decentplatforms.com.evil", nil, false},
+	}
+	for _, test := range tests {
+		patt, err := CompileCapturePattern(test.expr, test.fold)
+		if err != nil {
+			t.Errorf("%s: %s", test.expr, err)
+			continue
+		}
+		values, ok := patt.Capture(test.in)
+		if ok != test.ok || (ok && !reflect.DeepEqual(values, test.values)) {
+			t.Errorf("%s on %s: expected %v %v, got %v %v", test.expr, test.in, test.values, test.ok, values, ok)
+		}
+	}
+	patt, _ := CompileCapturePattern("[a].[b].com", false)
+	if names := patt.Names(); !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Errorf("expected names [a b], got %v", names)
+	}
+	for _, expr := range []string{"[tenant.com", "{.+.com", "[].com", "[a-b].com", "[a].[a].com", "{(}.com", "a}.com"} {
+		if _, err := CompileCapturePattern(expr, false); err == nil {
+			t.Errorf("%s: expected error", expr)
+		}
+	}
 }
@@ -0,0 +1,197 @@
+package route
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/decentplatforms/matcha/pkg/middleware"
+	"github.com/decentplatforms/matcha/pkg/path"
+	"github.com/decentplatforms/matcha/pkg/route/require"
+)
+
+// A Builder creates a Route part by part, instead of parsing an expression.
+// Each method adds parts to the end of the route and returns the Builder, so calls can be chained:
+//
+//	r, err := route.Build(http.MethodGet).Static("users").Param("id").Regex("n", "[0-9]+").Rest("path").Route()
+//
+// The Route's expression is built along with it, so Expr gives an expression that creates an equivalent Route.
+// If a method fails, the rest of the chain is ignored, and Route returns the error.
+type Builder struct {
+	method  string
+	tokens  []string
+	parts   []func() (Part, error)
+	partial bool
+	err     error
+}
+
+// Start building a Route for method.
+func Build(method string) *Builder {
+	return &Builder{method: method}
+}
+
+// add adds a part to the route, with token as its expression.
+// The part is built once to check it, and again for each Route created, so Routes never share parts.
+func (b *Builder) add(token string, part func() (Part, error)) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if b.partial {
+		b.err = fmt.Errorf("error building route %s: can't add %s after the rest of the route", b.expr(), token)
+		return b
+	}
+	if _, err := part(); err != nil {
+		b.err = fmt.Errorf("error building route %s: %w", b.expr()+token, err)
+		return b
+	}
+	b.tokens = append(b.tokens, token)
+	b.parts = append(b.parts, part)
+	return b
+}
+
+// expr gets the expression of the route so far.
+func (b *Builder) expr() string {
+	return strings.Join(b.tokens, "")
+}
+
+// checkName checks that a param name can be written in an expression.
+func checkName(name string) error {
+	if strings.ContainsAny(name, "/[]{}+") {
+		return fmt.Errorf("invalid param name %q", name)
+	}
+	return nil
+}
+
+// Add static parts that match the segments of s exactly, like "users" or "api/v1".
+// A leading slash is optional, and a trailing slash adds a part that matches a trailing slash in the request.
+func (b *Builder) Static(s string) *Builder {
+	if !strings.HasPrefix(s, "/") {
+		s = "/" + s
+	}
+	for next := 0; next != -1; {
+		var token string
+		token, next = path.Next(s, next)
+		b.add(token, func() (Part, error) {
+			if strings.HasSuffix(token, "+") {
+				return nil, fmt.Errorf("static part %s can't end with +", token)
+			}
+			return build_stringPart(token)
+		})
+	}
+	return b
+}
+
+// Add a wildcard part that matches any segment, and sets it as param name.
+func (b *Builder) Param(name string) *Builder {
+	return b.add("/["+name+"]", func() (Part, error) {
+		if err := checkName(name); err != nil {
+			return nil, err
+		}
+		return build_wildcardPart(name)
+	})
+}
+
+// Add a regex part that matches segments that match expr entirely, and sets them as param name.
+// If name is empty, the part doesn't set a param.
+func (b *Builder) Regex(name, expr string) *Builder {
+	token := "/{" + expr + "}"
+	if name != "" {
+		token = "/[" + name + "]{" + expr + "}"
+	}
+	return b.add(token, func() (Part, error) {
+		if err := checkName(name); err != nil {
+			return nil, err
+		}
+		if strings.Contains(expr, "/") {
+			return nil, fmt.Errorf("regex %s can't contain a slash, since it would be split in the route's expression", expr)
+		}
+		return build_regexPart(name, expr)
+	})
+}
+
+// Make the route partial, matching the rest of the path and setting it as param name.
+// If name is empty, the part doesn't set a param. No parts can be added after Rest.
+func (b *Builder) Rest(name string) *Builder {
+	token := "/+"
+	if name != "" {
+		token = "/[" + name + "]+"
+	}
+	b.add(token, func() (Part, error) {
+		if err := checkName(name); err != nil {
+			return nil, err
+		}
+		return &partialEndPart{param: name, subPart: &wildcardPart{}}, nil
+	})
+	b.partial = b.err == nil
+	return b
+}
+
+// Add a part parsed from a single token of a route expression, like "[id]{[0-9]+}", with the syntax built into
+// this package or a parser registered with RegisterParser. A leading slash is optional.
+// Tokens ending with "+" make the route partial, like Rest.
+func (b *Builder) Token(token string) *Builder {
+	if !strings.HasPrefix(token, "/") {
+		token = "/" + token
+	}
+	partial := isPartialRouteExpr(token)
+	b.add(token, func() (Part, error) {
+		if strings.Contains(token[1:], "/") {
+			return nil, fmt.Errorf("token %s has more than one segment", token)
+		}
+		if partial {
+			return parse_partialEndPart(token)
+		}
+		return parse(token)
+	})
+	b.partial = partial && b.err == nil
+	return b
+}
+
+// Create the Route, and apply confs to it as with New.
+// A Builder can create any number of Routes; with no parts, it creates a Route for "/".
+func (b *Builder) Route(confs ...ConfigFunc) (Route, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.parts) == 0 {
+		return New(b.method, "/", confs...)
+	}
+	parts := make([]Part, len(b.parts))
+	for i, part := range b.parts {
+		p, err := part()
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = p
+	}
+	var r Route
+	if b.partial {
+		r = &partialRoute{
+			origExpr: b.expr(),
+			method:   b.method,
+			parts:    parts,
+		}
+	} else {
+		r = &defaultRoute{
+			origExpr:   b.expr(),
+			method:     b.method,
+			parts:      parts,
+			middleware: make([]middleware.Middleware, 0),
+			required:   make([]require.Required, 0),
+		}
+	}
+	for _, conf := range confs {
+		if err := conf(r); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Create the Route like Route, and panic if this fails.
+func (b *Builder) Declare(confs ...ConfigFunc) Route {
+	r, err := b.Route(confs...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
@@ -0,0 +1,186 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/decentplatforms/matcha/pkg/rctx"
+)
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		b    *Builder
+		expr string
+	}{
+		{Build(http.MethodGet).Static("users").Param("id"), "/users/[id]"},
+		{Build(http.MethodGet).Static("/api/v1").Regex("n", "[0-9]+"), "/api/v1/[n]{[0-9]+}"},
+		{Build(http.MethodGet).Static("files").Regex("", "[a-z]+").Rest("path"), "/files/{[a-z]+}/[path]+"},
+		{Build(http.MethodGet).Static("static").Rest(""), "/static/+"},
+		{Build(http.MethodGet).Static("users").Token("[id]{[0-9]+}").Token("/[rest]{[a-z]+}+"), "/users/[id]{[0-9]+}/[rest]{[a-z]+}+"},
+		{Build(http.MethodGet).Static("api/"), "/api/"},
+		{Build(http.MethodGet), "/"},
+	}
+	for _, test := range tests {
+		r, err := test.b.Route()
+		if err != nil {
+			t.Errorf("%s: %s", test.expr, err)
+			continue
+		}
+		if ExprOf(r) != test.expr || r.Hash() != "GET "+test.expr {
+			t.Errorf("expected expression %s, got %s", test.expr, ExprOf(r))
+		}
+		// Built routes are the same as routes parsed from their expressions.
+		parsed := Declare(http.MethodGet, ExprOf(r))
+		if reflect.TypeOf(r) != reflect.TypeOf(parsed) || len(r.Parts()) != len(parsed.Parts()) {
+			t.Errorf("%s: expected %T with %d parts, got %T with %d", test.expr, parsed, len(parsed.Parts()), r, len(r.Parts()))
+			continue
+		}
+		for i, p := range r.Parts() {
+			if !p.Eq(parsed.Parts()[i]) {
+				t.Errorf("%s: expected part %d to equal %s", test.expr, i, describe(parsed.Parts()[i]))
+			}
+		}
+	}
+	r := Build(http.MethodGet).Static("files").Param("user").Rest("path").Declare()
+	req := rctx.PrepareRequestContext(httptest.NewRequest(http.MethodGet, "/files/jnichols/docs/a.txt", nil), NumParams(r))
+	if req = r.MatchAndUpdateContext(req); req == nil {
+		t.Fatal("expected built route to match")
+	}
+	if user, p := rctx.GetParam(req.Context(), "user"), rctx.GetParam(req.Context(), "path"); user != "jnichols" || p != "/docs/a.txt" {
+		t.Errorf("expected user jnichols and path /docs/a.txt, got %s and %s", user, p)
+	}
+}
+
+func TestBuildRoutes(t *testing.T) {
+	// Routes created by the same Builder don't share parts.
+	b := Build(http.MethodGet).Static("Users")
+	folded, err := b.Route(CaseInsensitive())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs := b.Declare()
+	if req := httptest.NewRequest(http.MethodGet, "/users", nil); folded.MatchAndUpdateContext(rctx.PrepareRequestContext(req, 0)) == nil {
+		t.Error("expected case-insensitive route to match")
+	}
+	if req := httptest.NewRequest(http.MethodGet, "/users", nil); cs.MatchAndUpdateContext(rctx.PrepareRequestContext(req, 0)) != nil {
+		t.Error("expected route from the same Builder to stay case-sensitive")
+	}
+	if _, err := b.Route(invalidConfigFunc); err == nil {
+		t.Error("expected invalid config to fail")
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		b    *Builder
+	}{
+		{"param name", Build(http.MethodGet).Param("a/b")},
+		{"regex", Build(http.MethodGet).Regex("n", "(")},
+		{"regex slash", Build(http.MethodGet).Regex("n", "[^/]+")},
+		{"static brackets", Build(http.MethodGet).Static("[id]")},
+		{"static plus", Build(http.MethodGet).Static("a+")},
+		{"after rest", Build(http.MethodGet).Rest("path").Param("id")},
+		{"token segments", Build(http.MethodGet).Token("a/b")},
+		{"token", Build(http.MethodGet).Token("[id]{(}")},
+		// The first error is kept.
+		{"chain", Build(http.MethodGet).Param("[").Static("users")},
+	}
+	for _, test := range tests {
+		if _, err := test.b.Route(); err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("expected Declare to panic")
+		}
+	}()
+	Build(http.MethodGet).Param("[").Declare()
+}
+
+// evenPart matches segments with an even number of characters.
+type evenPart struct {
+	param string
+}
+
+func (part *evenPart) Match(ctx context.Context, token string) bool {
+	if len(token[1:])%2 != 0 {
+		return false
+	}
+	if ctx != nil && part.param != "" {
+		rctx.SetParam(ctx, part.param, token[1:])
+	}
+	return true
+}
+
+func (part *evenPart) Eq(other Part) bool {
+	if o, ok := other.(*evenPart); ok {
+		return o.param == part.param
+	}
+	return false
+}
+
+func (part *evenPart) ParameterName() string {
+	return part.param
+}
+
+func (part *evenPart) SetParameterName(s string) {
+	part.param = s
+}
+
+func parseEven(token string) (Part, bool, error) {
+	param, ok := strings.CutSuffix(token, ":even]")
+	if !ok || !strings.HasPrefix(param, "/[") {
+		return nil, false, nil
+	}
+	if param == "/[" {
+		return nil, true, errors.New("even parts need a param")
+	}
+	return &evenPart{param: param[2:]}, true, nil
+}
+
+func TestRegisterParser(t *testing.T) {
+	RegisterParser("even", parseEven)
+	defer RegisterParser("even", nil)
+	r, err := New(http.MethodGet, "/users/[id:even]/files/[names:even]+")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Parts()[1].(*evenPart); !ok {
+		t.Fatalf("expected registered part, got %T", r.Parts()[1])
+	}
+	if names := Params(r); !reflect.DeepEqual(names, []string{"id", "names"}) {
+		t.Errorf("expected params id and names, got %v", names)
+	}
+	req := rctx.PrepareRequestContext(httptest.NewRequest(http.MethodGet, "/users/ab/files/cd/efgh", nil), NumParams(r))
+	if req = r.MatchAndUpdateContext(req); req == nil {
+		t.Fatal("expected route to match")
+	}
+	if id, names := rctx.GetParam(req.Context(), "id"), rctx.GetParam(req.Context(), "names"); id != "ab" || names != "/cd/efgh" {
+		t.Errorf("expected id ab and names /cd/efgh, got %s and %s", id, names)
+	}
+	if req := httptest.NewRequest(http.MethodGet, "/users/abc/files", nil); r.MatchAndUpdateContext(rctx.PrepareRequestContext(req, 2)) != nil {
+		t.Error("expected odd id not to match")
+	}
+	if path, err := Reverse(r, map[string]string{"id": "ab", "names": "cd/ef"}); err != nil || path != "/users/ab/files/cd/ef" {
+		t.Errorf("expected reversed path /users/ab/files/cd/ef, got %s (%v)", path, err)
+	}
+	// The builder uses registered parsers too.
+	if built := Build(http.MethodGet).Static("users").Token("[id:even]").Declare(); !built.Parts()[1].Eq(r.Parts()[1]) {
+		t.Error("expected built part to equal parsed part")
+	}
+	if _, err := New(http.MethodGet, "/[:even]"); err == nil {
+		t.Error("expected parser error")
+	}
+	// Once the parser is removed, the syntax is parsed as usual.
+	RegisterParser("even", nil)
+	if p := Declare(http.MethodGet, "/[id:even]").Parts()[0]; KindOf(p) != KindWildcard {
+		t.Errorf("expected wildcard part after removing parser, got %T", p)
+	}
+}
@@ -1,6 +1,9 @@
 package route
 
 import (
+	"errors"
+	"time"
+
 	"github.com/decentplatforms/matcha/pkg/cors"
 	"github.com/decentplatforms/matcha/pkg/middleware"
 	"github.com/decentplatforms/matcha/pkg/route/require"
@@ -9,14 +12,29 @@ import (
 // RouteConfigFuncs can be applied to a Route at creation.
 type ConfigFunc func(Route) error
 
+type corsKey struct{}
+type nameKey struct{}
+type foldKey struct{}
+type timeoutKey struct{}
+
 // Attaches middleware to the route that sets CORS headers on matched requests only.
+// The options are also stored on the route, so routers can use them to answer preflight requests.
 func CORSHeaders(aco *cors.AccessControlOptions) ConfigFunc {
 	return func(r Route) error {
 		r.Attach(cors.CORSMiddleware(aco))
+		if v, ok := r.(Valuer); ok {
+			v.SetValue(corsKey{}, aco)
+		}
 		return nil
 	}
 }
 
+// Get the access control options set on a route by CORSHeaders, or nil if there are none.
+func GetCORSHeaders(r Route) *cors.AccessControlOptions {
+	aco, _ := valueOf(r, corsKey{}).(*cors.AccessControlOptions)
+	return aco
+}
+
 // Attaches middleware to the route.
 func WithMiddleware(mws ...middleware.Middleware) ConfigFunc {
 	return func(r Route) error {
@@ -31,3 +49,84 @@ func Require(rs ...require.Required) ConfigFunc {
 		return nil
 	}
 }
+
+// Names the route, so routers can build URLs for it by name.
+func Name(name string) ConfigFunc {
+	return func(r Route) error {
+		return setValue(r, nameKey{}, name)
+	}
+}
+
+// Get the name set on a route by Name, or "" if it isn't named.
+func GetName(r Route) string {
+	name, _ := valueOf(r, nameKey{}).(string)
+	return name
+}
+
+// Makes the static parts of the route match request paths case-insensitively.
+// Params keep the casing of the request, and regex parts are matched as they are; use the (?i) flag to match
+// them case-insensitively.
+func CaseInsensitive() ConfigFunc {
+	return func(r Route) error {
+		if err := setValue(r, foldKey{}, true); err != nil {
+			return err
+		}
+		foldParts(r)
+		return nil
+	}
+}
+
+// Get a copy of r made case-insensitive as by CaseInsensitive, without changing r.
+// If r is already case-insensitive, or isn't implemented in this package and can't be copied, r is returned.
+func Fold(r Route) Route {
+	if IsCaseInsensitive(r) {
+		return r
+	}
+	var c Route
+	switch route := r.(type) {
+	case *defaultRoute:
+		cp := *route
+		cp.parts = copyParts(route.parts)
+		cp.middleware = append(make([]middleware.Middleware, 0, len(route.middleware)), route.middleware...)
+		cp.required = append(make([]require.Required, 0, len(route.required)), route.required...)
+		cp.values = copyValues(route.values)
+		c = &cp
+	case *partialRoute:
+		cp := *route
+		cp.parts = copyParts(route.parts)
+		cp.middleware = append(make([]middleware.Middleware, 0, len(route.middleware)), route.middleware...)
+		cp.required = append(make([]require.Required, 0, len(route.required)), route.required...)
+		cp.values = copyValues(route.values)
+		c = &cp
+	default:
+		return r
+	}
+	CaseInsensitive()(c)
+	return c
+}
+
+// Check if a route was made case-insensitive by CaseInsensitive.
+func IsCaseInsensitive(r Route) bool {
+	fold, _ := valueOf(r, foldKey{}).(bool)
+	return fold
+}
+
+// Limits how long the route's handler and middleware have to respond.
+// Matched requests get a context deadline of d, and if they aren't done by then, routers respond with their
+// timeout handler instead. This overrides the router's default timeout; a timeout of 0 disables it for the route.
+// Returns an error if d is negative.
+func Timeout(d time.Duration) ConfigFunc {
+	return func(r Route) error {
+		if d < 0 {
+			return errors.New("route timeout must not be negative")
+		}
+		return setValue(r, timeoutKey{}, d)
+	}
+}
+
+// Get the timeout set on a route by Timeout.
+// Returns ok == false if the route doesn't have a timeout, so the router's default applies.
+func GetTimeout(r Route) (d time.Duration, ok bool) {
+	d, ok = valueOf(r, timeoutKey{}).(time.Duration)
+	return
+}
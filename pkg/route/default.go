@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"strings"
 
 	"github.com/decentplatforms/matcha/pkg/middleware"
 	"github.com/decentplatforms/matcha/pkg/path"
@@ -23,20 +24,24 @@ import (
 // stringPart; literal string part
 type stringPart struct {
 	val string
+	// fold makes the part match tokens case-insensitively.
+	fold bool
 }
 
 func build_stringPart(val string) (*stringPart, error) {
 	if url.PathEscape(val[1:]) != val[1:] {
 		return nil, errors.New("static part " + val + " is not a valid URL part (" + url.PathEscape(val) + ")")
 	} else {
-		return &stringPart{val}, nil
+		return &stringPart{val: val}, nil
 	}
 }
 
-// stringParts match a literal token exactly.
+// stringParts match a literal token exactly, or ignoring case if they fold.
 func (part *stringPart) Match(ctx context.Context, token string) bool {
 	if part.val == token {
 		return true
+	} else if part.fold {
+		return strings.EqualFold(part.val, token)
 	} else {
 		return false
 	}
@@ -44,7 +49,7 @@ func (part *stringPart) Match(ctx context.Context, token string) bool {
 
 func (part *stringPart) Eq(other Part) bool {
 	if otherSp, ok := other.(*stringPart); ok {
-		return otherSp.val == part.val
+		return otherSp.val == part.val && otherSp.fold == part.fold
 	}
 	return false
 }
@@ -94,23 +99,24 @@ func (part *wildcardPart) SetParameterName(s string) {
 type regexPart struct {
 	param string
 	expr  *regexp.Regexp
+	// whole is expr anchored to match whole tokens.
+	whole *regexp.Regexp
 }
 
 func build_regexPart(param, expr string) (*regexPart, error) {
 	expr_compiled, err := regexp.Compile(expr)
 	if err != nil {
 		return nil, err
-	} else {
-		return &regexPart{param, expr_compiled}, nil
 	}
+	return &regexPart{param, expr_compiled, regexp.MustCompile(`^(?:` + expr + `)$`)}, nil
 }
 
-// regexParts match any token that matches the regex value.
+// regexParts match any token that the regex value matches entirely.
 func (part *regexPart) Match(ctx context.Context, token string) bool {
 	token = token[1:]
-	// Match against regex
-	matched := part.expr.FindString(token)
-	if matched != token {
+	// Match against the anchored regex, so alternations like a|ab match any of their branches in full, and empty
+	// tokens only match regexes that match the empty string.
+	if !part.whole.MatchString(token) {
 		return false
 	}
 	// If a parameter is set, act as a wildcard param.
@@ -145,6 +151,7 @@ type defaultRoute struct {
 	parts      []Part
 	middleware []middleware.Middleware
 	required   []require.Required
+	values     map[any]any
 }
 
 // Tokenize and parse a route expression into a defaultRoute.
@@ -193,6 +200,13 @@ func (route *defaultRoute) Hash() string {
 	return route.method + " " + route.origExpr
 }
 
+// Get the expression the route was created from, with consecutive slashes collapsed.
+//
+// See interface Route.
+func (route *defaultRoute) Expr() string {
+	return route.origExpr
+}
+
 // Get the length of the route.
 // For defaultRoutes, this is the total number of Parts it contains.
 //
@@ -258,3 +272,14 @@ func (route *defaultRoute) Middleware() []middleware.Middleware {
 func (route *defaultRoute) Required() []require.Required {
 	return route.required
 }
+
+func (route *defaultRoute) SetValue(key, value any) {
+	if route.values == nil {
+		route.values = make(map[any]any)
+	}
+	route.values[key] = value
+}
+
+func (route *defaultRoute) Value(key any) any {
+	return route.values[key]
+}
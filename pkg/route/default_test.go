@@ -93,6 +93,9 @@ func TestRegexPart(t *testing.T) {
 	if ok := rp1.Match(nil, "/longword"); ok {
 		t.Errorf("rp1 should not Match token /longword")
 	}
+	if ok := rp1.Match(nil, "/"); ok {
+		t.Errorf("rp1 should not Match empty token /")
+	}
 	rp2, _ := build_regexPart("", "[a-z]{4}")
 	if !rp1.Eq(rp2) {
 		t.Errorf("rp1 should Eq regex part with same param/expr")
@@ -121,3 +124,32 @@ func TestRegexPart(t *testing.T) {
 		t.Errorf("expected param 'word', got '%s'", rctx.GetParam(rmc, "param"))
 	}
 }
+
+func TestRegexPartWholeToken(t *testing.T) {
+	tests := []struct {
+		expr  string
+		token string
+		match bool
+	}{
+		{"[a-z]+", "/word", true},
+		{"[a-z]+", "/word1", false},
+		{"[a-z]+", "/1word", false},
+		{"[a-z]+", "/", false},
+		{"[a-z]*", "/", true},
+		{"[a-z]*", "/1", false},
+		// Leftmost matches that don't cover the token don't hide branches that do.
+		{"a|ab", "/ab", true},
+		{"a|ab", "/abc", false},
+		{"(?i)abc", "/ABC", true},
+		{"x$|y", "/y", true},
+	}
+	for _, test := range tests {
+		rp, err := build_regexPart("", test.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok := rp.Match(nil, test.token); ok != test.match {
+			t.Errorf("%s: expected Match(%s) to be %t", test.expr, test.token, test.match)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package route_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/decentplatforms/matcha/pkg/rctx"
+	"github.com/decentplatforms/matcha/pkg/route"
+	"github.com/decentplatforms/matcha/pkg/router"
+)
+
+// uuidPart matches UUIDs like 123e4567-e89b-12d3-a456-426614174000, without a regexp.
+type uuidPart struct {
+	param string
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return false
+			}
+		case '0' <= c && c <= '9', 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (part *uuidPart) Match(ctx context.Context, token string) bool {
+	if !isUUID(token[1:]) {
+		return false
+	}
+	if ctx != nil && part.param != "" {
+		rctx.SetParam(ctx, part.param, token[1:])
+	}
+	return true
+}
+
+func (part *uuidPart) Eq(other route.Part) bool {
+	if o, ok := other.(*uuidPart); ok {
+		return o.param == part.param
+	}
+	return false
+}
+
+// ParameterName and SetParameterName let routers allocate the part's param.
+func (part *uuidPart) ParameterName() string {
+	return part.param
+}
+
+func (part *uuidPart) SetParameterName(s string) {
+	part.param = s
+}
+
+// parseUUID parses tokens like /[id:uuid].
+func parseUUID(token string) (route.Part, bool, error) {
+	if !strings.HasPrefix(token, "/[") || !strings.HasSuffix(token, ":uuid]") {
+		return nil, false, nil
+	}
+	return &uuidPart{param: token[2 : len(token)-len(":uuid]")]}, true, nil
+}
+
+func ExampleRegisterParser() {
+	route.RegisterParser("uuid", parseUUID)
+	defer route.RegisterParser("uuid", nil)
+
+	rt := router.Declare(
+		router.Default(),
+		router.HandleFunc(http.MethodGet, "/users/[id:uuid]", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, rctx.GetParam(r.Context(), "id"))
+		}),
+		router.HandleRoute(route.Build(http.MethodGet).Static("users").Token("[id:uuid]").Static("posts").Declare(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "posts by ", rctx.GetParam(r.Context(), "id"))
+		})),
+	)
+	for _, p := range []string{
+		"/users/123e4567-e89b-12d3-a456-426614174000",
+		"/users/123e4567-e89b-12d3-a456-426614174000/posts",
+		"/users/12",
+	} {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, p, nil))
+		fmt.Println(w.Code, w.Body.String())
+	}
+	// Output:
+	// 200 123e4567-e89b-12d3-a456-426614174000
+	// 200 posts by 123e4567-e89b-12d3-a456-426614174000
+	// 404
+}
+
+func ExampleBuild() {
+	r := route.Build(http.MethodGet).Static("files").Param("user").Regex("kind", "docs|images").Rest("path").Declare()
+	fmt.Println(route.ExprOf(r))
+	// Output:
+	// /files/[user]/[kind]{docs|images}/[path]+
+}
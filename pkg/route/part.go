@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"sync"
 
 	"github.com/decentplatforms/matcha/pkg/regex"
 )
@@ -30,6 +31,44 @@ type Part interface {
 	Eq(other Part) bool
 }
 
+// PartKinds describe how specific a Part is. Lower kinds are more specific.
+type PartKind int
+
+const (
+	// Static string parts, which match exactly one token.
+	KindStatic PartKind = iota
+	// Regex parts, which match tokens that match their regex, with or without a param.
+	// Part implementations from outside this package are also regex parts.
+	KindRegex
+	// Wildcard parts, which match any token.
+	KindWildcard
+	// Partial parts, which match any number of tokens at the end of a route.
+	KindPartial
+)
+
+// Get the kind of a Part.
+func KindOf(p Part) PartKind {
+	switch p.(type) {
+	case *stringPart:
+		return KindStatic
+	case *wildcardPart:
+		return KindWildcard
+	case *partialEndPart:
+		return KindPartial
+	default:
+		return KindRegex
+	}
+}
+
+// Get the token a static Part matches, and whether it matches tokens case-insensitively.
+// Returns ok == false if p isn't static.
+func StaticToken(p Part) (token string, fold bool, ok bool) {
+	if sp, isStatic := p.(*stringPart); isStatic {
+		return sp.val, sp.fold, true
+	}
+	return "", false, false
+}
+
 // paramParts may or may not store some parameter.
 // This is for internal use in package route only, so that extensions of Part/Route can specialize behavior
 // for Parts that do or don't have parameters.
@@ -38,8 +77,71 @@ type paramPart interface {
 	SetParameterName(string)
 }
 
+// A PartParser parses a token of a route expression, including its leading slash, into a Part.
+// Returns ok == false if the parser doesn't handle the token's syntax, so the token is parsed as usual.
+//
+// Parts that set a param should have methods ParameterName() string and SetParameterName(string), like the Parts
+// in this package, so routers allocate their params and partial routes can move the param to the partial part.
+type PartParser func(token string) (p Part, ok bool, err error)
+
+type namedParser struct {
+	name  string
+	parse PartParser
+}
+
+// parsers are the registered PartParsers, in the order they were registered.
+var (
+	parsersMu sync.RWMutex
+	parsers   []namedParser
+)
+
+// Register a PartParser with a name, so route expressions can use the syntax it handles.
+// Registered parsers are tried in the order they're registered, before the syntax built into this package.
+// Registering a parser with the name of a registered parser replaces it in place; if parse is nil, the parser is
+// removed instead. Routes created before the parser is registered aren't affected.
+func RegisterParser(name string, parse PartParser) {
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+	for i, np := range parsers {
+		if np.name != name {
+			continue
+		}
+		if parse == nil {
+			parsers = append(parsers[:i:i], parsers[i+1:]...)
+		} else {
+			parsers[i].parse = parse
+		}
+		return
+	}
+	if parse != nil {
+		parsers = append(parsers, namedParser{name, parse})
+	}
+}
+
+// parseRegistered parses a token with the first registered parser that handles it.
+func parseRegistered(token string) (Part, bool, error) {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+	for _, np := range parsers {
+		p, ok, err := np.parse(token)
+		if err != nil {
+			return nil, true, fmt.Errorf("error parsing expression %s with parser %s: %w", token, np.name, err)
+		}
+		if ok && p == nil {
+			return nil, true, fmt.Errorf("error parsing expression %s with parser %s: no part", token, np.name)
+		}
+		if ok {
+			return p, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
 // Parse a token into a route Part.
 func parse(token string) (Part, error) {
+	if p, ok, err := parseRegistered(token); ok {
+		return p, err
+	}
 	// wildcard check
 	if groups := regex.Groups(regexp_wildcard_compiled, token); groups != nil {
 		// There must be at least one group here.
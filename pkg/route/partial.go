@@ -101,6 +101,7 @@ type partialRoute struct {
 	parts      []Part
 	middleware []middleware.Middleware
 	required   []require.Required
+	values     map[any]any
 }
 
 // Tokenize and parse a route expression into a partialRoute.
@@ -157,6 +158,13 @@ func (route *partialRoute) Hash() string {
 	return route.method + " " + route.origExpr
 }
 
+// Get the expression the route was created from, with consecutive slashes collapsed.
+//
+// See interface Route.
+func (route *partialRoute) Expr() string {
+	return route.origExpr
+}
+
 // Get the length of the route.
 // For partialRoutes, this is the number of *absolute* parts; the adaptive part at the end is excluded.
 // This ensures that when matching for longest route, the more specialized route is always picked.
@@ -232,3 +240,14 @@ func (route *partialRoute) Middleware() []middleware.Middleware {
 func (route *partialRoute) Required() []require.Required {
 	return route.required
 }
+
+func (route *partialRoute) SetValue(key, value any) {
+	if route.values == nil {
+		route.values = make(map[any]any)
+	}
+	route.values[key] = value
+}
+
+func (route *partialRoute) Value(key any) any {
+	return route.values[key]
+}
@@ -0,0 +1,86 @@
+package route
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// describe a Part for error messages.
+func describe(p Part) string {
+	switch part := p.(type) {
+	case *stringPart:
+		return part.val
+	case *wildcardPart:
+		return "/[" + part.param + "]"
+	case *regexPart:
+		return "/[" + part.param + "]{" + part.expr.String() + "}"
+	case *partialEndPart:
+		return describe(part.subPart) + "+"
+	case fmt.Stringer:
+		return part.String()
+	default:
+		return fmt.Sprintf("%T", p)
+	}
+}
+
+// reverseToken checks a param value against a Part and returns the value as an escaped path token.
+func reverseToken(r Route, p Part, param, value string) (string, error) {
+	if value == "" {
+		return "", fmt.Errorf("route %s: param %s is empty", describeRoute(r), param)
+	}
+	if !p.Match(nil, "/"+value) {
+		return "", fmt.Errorf("route %s: param %s value %q does not match %s", describeRoute(r), param, value, describe(p))
+	}
+	return "/" + url.PathEscape(value), nil
+}
+
+// Build the path for a route from a set of params.
+// Param values are escaped, and must match the parts that set them. Partial params may contain multiple path
+// segments separated by "/", each of which must match the partial part; they may also be empty or missing.
+//
+// Reverse returns an error if a param is missing or doesn't match, or if the route has a part that can't be
+// built, like a regex part without a param name.
+func Reverse(r Route, params map[string]string) (string, error) {
+	var sb strings.Builder
+	for _, p := range r.Parts() {
+		switch part := p.(type) {
+		case *stringPart:
+			sb.WriteString(part.val)
+		case *partialEndPart:
+			if part.param == "" {
+				continue
+			}
+			for _, seg := range strings.Split(params[part.param], "/") {
+				if seg == "" {
+					continue
+				}
+				token, err := reverseToken(r, part.subPart, part.param, seg)
+				if err != nil {
+					return "", err
+				}
+				sb.WriteString(token)
+			}
+		case paramPart:
+			name := part.ParameterName()
+			if name == "" {
+				return "", fmt.Errorf("route %s: part %s has no param name, so its value can't be built", describeRoute(r), describe(p))
+			}
+			value, ok := params[name]
+			if !ok {
+				return "", fmt.Errorf("route %s: missing param %s", describeRoute(r), name)
+			}
+			token, err := reverseToken(r, p, name, value)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(token)
+		default:
+			return "", fmt.Errorf("route %s: part %s can't be built", describeRoute(r), describe(p))
+		}
+	}
+	if sb.Len() == 0 {
+		return "/", nil
+	}
+	return sb.String(), nil
+}
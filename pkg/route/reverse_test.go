@@ -0,0 +1,59 @@
+package route
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReverse(t *testing.T) {
+	tests := []struct {
+		expr   string
+		params map[string]string
+		path   string
+		err    string
+	}{
+		{"/", nil, "/", ""},
+		{"/static/route", nil, "/static/route", ""},
+		{"/static/route/", nil, "/static/route/", ""},
+		{"/users/[id]/posts", map[string]string{"id": "12"}, "/users/12/posts", ""},
+		{"/users/[id]", map[string]string{"id": "a b/c"}, "/users/a%20b%2Fc", ""},
+		{"/users/[id]{[0-9]+}", map[string]string{"id": "12"}, "/users/12", ""},
+		{"/files/[path]+", map[string]string{"path": "a/b c/d.txt"}, "/files/a/b%20c/d.txt", ""},
+		{"/files/[path]+", map[string]string{"path": "/a/b"}, "/files/a/b", ""},
+		{"/files/[path]+", nil, "/files", ""},
+		{"/files/[name]{\\w+\\.md}+", map[string]string{"name": "a.md/b.md"}, "/files/a.md/b.md", ""},
+		{"/files/+", nil, "/files", ""},
+		{"/users/[id]", nil, "", "missing param id"},
+		{"/users/[id]", map[string]string{"id": ""}, "", "param id is empty"},
+		{"/users/[id]{[0-9]+}", map[string]string{"id": "1a"}, "", `param id value "1a" does not match /[id]{[0-9]+}`},
+		{"/users/[id]{[0-9]+}", map[string]string{"id": "a12"}, "", "does not match"},
+		{"/files/[name]{\\w+\\.md}+", map[string]string{"name": "a.md/b.txt"}, "", `param name value "b.txt" does not match`},
+		{"/users/{[0-9]+}", nil, "", "has no param name"},
+	}
+	for _, test := range tests {
+		r := Declare(http.MethodGet, test.expr)
+		path, err := Reverse(r, test.params)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected error containing %q, got %v", test.expr, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.expr, err)
+		} else if path != test.path {
+			t.Errorf("%s: expected %s, got %s", test.expr, test.path, path)
+		}
+	}
+}
+
+func TestName(t *testing.T) {
+	r := Declare(http.MethodGet, "/users/[id]", Name("user"))
+	if name := GetName(r); name != "user" {
+		t.Errorf("expected name user, got %q", name)
+	}
+	if name := GetName(Declare(http.MethodGet, "/")); name != "" {
+		t.Errorf("expected no name, got %q", name)
+	}
+}
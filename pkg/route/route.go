@@ -4,9 +4,12 @@
 package route
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/decentplatforms/matcha/pkg/middleware"
+	"github.com/decentplatforms/matcha/pkg/path"
 	"github.com/decentplatforms/matcha/pkg/route/require"
 )
 
@@ -49,6 +52,61 @@ type Route interface {
 	Required() []require.Required
 }
 
+// A Valuer is a Route that can carry values.
+// Values carry information about the route that isn't used for matching, like CORS options or names.
+// Routes created by this package are Valuers; ConfigFuncs that set values return an error for Routes that aren't.
+type Valuer interface {
+	// Set a value on the route.
+	//
+	// Keys should be unexported types, as with context.WithValue.
+	SetValue(key, value any)
+	// Get a value set on the route, or nil if it isn't set.
+	Value(key any) any
+}
+
+// An Exprer is a Route that can give the expression it was created from.
+// Routes created by this package are Exprers; Join returns an error for Routes that aren't.
+type Exprer interface {
+	// Get the expression the route was created from.
+	//
+	// Route implementations may normalize the expression, but must return an expression that creates an equivalent Route.
+	Expr() string
+}
+
+// Get the expression r was created from, or "" if r isn't an Exprer.
+func ExprOf(r Route) string {
+	if e, ok := r.(Exprer); ok {
+		return e.Expr()
+	}
+	return ""
+}
+
+// describeRoute describes a route in errors, by its expression if it has one, or its hash.
+func describeRoute(r Route) string {
+	if e, ok := r.(Exprer); ok {
+		return e.Expr()
+	}
+	return r.Hash()
+}
+
+// setValue sets a value on a route, or returns an error if the route isn't a Valuer.
+func setValue(r Route, key, value any) error {
+	v, ok := r.(Valuer)
+	if !ok {
+		return fmt.Errorf("route %s of type %T can't carry values", describeRoute(r), r)
+	}
+	v.SetValue(key, value)
+	return nil
+}
+
+// valueOf gets a value set on a route, or nil if it isn't set or the route isn't a Valuer.
+func valueOf(r Route, key any) any {
+	if v, ok := r.(Valuer); ok {
+		return v.Value(key)
+	}
+	return nil
+}
+
 // Create a new Route based on a string expression.
 func New(method, expr string, confs ...ConfigFunc) (Route, error) {
 	// Determine route type
@@ -80,3 +138,32 @@ func Declare(method, expr string, confs ...ConfigFunc) Route {
 	}
 	return r
 }
+
+// Create a new Route by joining the expression of r onto a prefix expression.
+// confs are applied to the new Route first; then the middleware, requirements, and values of r are copied onto it
+// (values are only copied from Routes created by this package),
+// so the new Route runs the middleware attached by confs before the middleware of r.
+//
+// Returns an error if the prefix is a partial expression, or if the joined expression is invalid.
+func Join(prefix string, r Route, confs ...ConfigFunc) (Route, error) {
+	if isPartialRouteExpr(prefix) {
+		return nil, errors.New("invalid prefix " + prefix + "; prefixes can't be partial")
+	}
+	e, ok := r.(Exprer)
+	if !ok {
+		return nil, fmt.Errorf("route %s of type %T can't be joined, since it has no expression", r.Hash(), r)
+	}
+	joined, err := New(r.Method(), path.Join(prefix, e.Expr()), confs...)
+	if err != nil {
+		return nil, err
+	}
+	joined.Attach(r.Middleware()...)
+	joined.Require(r.Required()...)
+	for key, value := range valuesOf(r) {
+		setValue(joined, key, value)
+	}
+	if IsCaseInsensitive(joined) {
+		foldParts(joined)
+	}
+	return joined, nil
+}
@@ -6,7 +6,9 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/decentplatforms/matcha/pkg/cors"
 	"github.com/decentplatforms/matcha/pkg/rctx"
@@ -389,6 +391,9 @@ func TestCORS(t *testing.T) {
 	if err != nil || len(rt.Middleware()) != 1 {
 		t.Fatal(err)
 	}
+	if got := GetCORSHeaders(rt); got != aco {
+		t.Errorf("expected CORS options to be stored on the route, got %v", got)
+	}
 	u, _ := url.Parse("http://test.com/static/path")
 	req := &http.Request{
 		Method: http.MethodGet,
@@ -408,6 +413,9 @@ func TestCORS(t *testing.T) {
 	if err != nil || len(rt.Middleware()) != 1 {
 		t.Fatal(err)
 	}
+	if got := GetCORSHeaders(rt); got != nil {
+		t.Errorf("expected no CORS options on the route, got %v", got)
+	}
 	u, _ = url.Parse("http://test.com/static/path/with/addition")
 	req = &http.Request{
 		Method: http.MethodGet,
@@ -471,3 +479,197 @@ func TestRequire(t *testing.T) {
 		t.Error("expected no match")
 	}
 }
+
+// plainRoute is a Route that isn't a Valuer.
+type plainRoute struct {
+	Route
+}
+
+func TestValues(t *testing.T) {
+	type key struct{}
+	for _, expr := range []string{"/static/path", "/partial/+"} {
+		rt, ok := Declare(http.MethodGet, expr).(Valuer)
+		if !ok {
+			t.Fatalf("expected %s to be a Valuer", expr)
+		}
+		if v := rt.Value(key{}); v != nil {
+			t.Errorf("expected no value, got %v", v)
+		}
+		rt.SetValue(key{}, "value")
+		if v := rt.Value(key{}); v != "value" {
+			t.Errorf("expected value, got %v", v)
+		}
+	}
+	// Routes that can't carry values still work with ConfigFuncs that don't need them.
+	plain := plainRoute{Declare(http.MethodGet, "/plain")}
+	if err := CORSHeaders(&cors.AccessControlOptions{})(plain); err != nil {
+		t.Errorf("expected CORSHeaders to work without values, got %s", err)
+	}
+	if len(plain.Middleware()) != 1 || GetCORSHeaders(plain) != nil {
+		t.Error("expected CORS middleware without stored options")
+	}
+	for name, conf := range map[string]ConfigFunc{"Name": Name("plain"), "Timeout": Timeout(time.Second), "CaseInsensitive": CaseInsensitive()} {
+		if err := conf(plain); err == nil {
+			t.Errorf("%s: expected error for a route without values", name)
+		}
+	}
+	if GetName(plain) != "" || IsCaseInsensitive(plain) {
+		t.Error("expected no values")
+	}
+}
+
+func TestExprOf(t *testing.T) {
+	r := Declare(http.MethodGet, "/users/[id]")
+	if expr := ExprOf(r); expr != "/users/[id]" {
+		t.Errorf("expected /users/[id], got %s", expr)
+	}
+	// Routes without expressions can't be joined, and are described by their hash.
+	plain := plainRoute{r}
+	if expr := ExprOf(plain); expr != "" {
+		t.Errorf("expected no expression, got %s", expr)
+	}
+	if _, err := Join("/api", plain); err == nil {
+		t.Error("expected Join to fail without an expression")
+	}
+	if _, err := Reverse(plain, nil); err == nil || !strings.Contains(err.Error(), plain.Hash()) {
+		t.Errorf("expected error to describe the route by its hash, got %v", err)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	mw := func(w http.ResponseWriter, req *http.Request) *http.Request { return req }
+	host := require.Hosts("decentplatforms.com")
+	r := Declare(http.MethodGet, "/repos/[repo]{[a-z]+}", WithMiddleware(mw), Name("repo"))
+	joined, err := Join("/orgs/[org]/", r, Require(host), WithMiddleware(mw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expr := ExprOf(joined); expr != "/orgs/[org]/repos/[repo]{[a-z]+}" {
+		t.Errorf("expected joined expression, got %s", expr)
+	}
+	if len(joined.Middleware()) != 2 || len(joined.Required()) != 1 {
+		t.Errorf("expected 2 middleware and 1 requirement, got %d and %d", len(joined.Middleware()), len(joined.Required()))
+	}
+	if name := GetName(joined); name != "repo" {
+		t.Errorf("expected name to be copied, got %q", name)
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://decentplatforms.com/orgs/decent/repos/matcha", nil)
+	req = rctx.PrepareRequestContext(req, 2)
+	if req = joined.MatchAndUpdateContext(req); req == nil {
+		t.Fatal("expected joined route to match")
+	}
+	if org := rctx.GetParam(req.Context(), "org"); org != "decent" {
+		t.Errorf("expected org decent, got %s", org)
+	}
+	partial, err := Join("/static", Declare(http.MethodGet, "/+"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expr := ExprOf(partial); expr != "/static/+" {
+		t.Errorf("expected /static/+, got %s", expr)
+	}
+	if _, err := Join("/static/+", r); err == nil {
+		t.Error("expected error for partial prefix")
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	r := Declare(http.MethodGet, "/static/{[0-9]+}/[id]{[0-9]+}/[name]/[rest]+")
+	expected := []PartKind{KindStatic, KindRegex, KindRegex, KindWildcard, KindPartial}
+	for i, p := range r.Parts() {
+		if kind := KindOf(p); kind != expected[i] {
+			t.Errorf("part %d: expected kind %d, got %d", i, expected[i], kind)
+		}
+	}
+}
+
+func TestStaticToken(t *testing.T) {
+	r := Declare(http.MethodGet, "/Static/[id]", CaseInsensitive())
+	if token, fold, ok := StaticToken(r.Parts()[0]); !ok || token != "/Static" || !fold {
+		t.Errorf("expected folding static token /Static, got %q (fold %v, ok %v)", token, fold, ok)
+	}
+	if _, _, ok := StaticToken(r.Parts()[1]); ok {
+		t.Error("expected wildcard part not to be static")
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	r := Declare(http.MethodGet, "/Users/[id]/Files/+", CaseInsensitive())
+	if !IsCaseInsensitive(r) {
+		t.Error("expected route to be case-insensitive")
+	}
+	req := httptest.NewRequest(http.MethodGet, "/USERS/AbC/files/Readme", nil)
+	req = rctx.PrepareRequestContext(req, 2)
+	if req = r.MatchAndUpdateContext(req); req == nil {
+		t.Fatal("expected case-insensitive route to match")
+	}
+	if id := rctx.GetParam(req.Context(), "id"); id != "AbC" {
+		t.Errorf("expected param to keep request casing, got %s", id)
+	}
+	if IsCaseInsensitive(Declare(http.MethodGet, "/users")) {
+		t.Error("expected route to be case-sensitive by default")
+	}
+	cs := Declare(http.MethodGet, "/Users")
+	if req := httptest.NewRequest(http.MethodGet, "/users", nil); cs.MatchAndUpdateContext(rctx.PrepareRequestContext(req, 0)) != nil {
+		t.Error("expected case-sensitive route not to match")
+	}
+	joined, err := Join("/API", Declare(http.MethodGet, "/Users", CaseInsensitive()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req := httptest.NewRequest(http.MethodGet, "/api/users", nil); joined.MatchAndUpdateContext(rctx.PrepareRequestContext(req, 0)) == nil {
+		t.Error("expected joined route to stay case-insensitive")
+	}
+	if cs.Parts()[0].Eq(joined.Parts()[1]) {
+		t.Error("expected folding part not to Eq case-sensitive part")
+	}
+}
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		r    Route
+		path string
+	}{
+		{Declare(http.MethodGet, "/Users/[id]"), "/users/AbC"},
+		{Declare(http.MethodGet, "/Static/Files+", Timeout(time.Minute)), "/static/files/FILES"},
+	}
+	for _, test := range tests {
+		folded := Fold(test.r)
+		if !IsCaseInsensitive(folded) || IsCaseInsensitive(test.r) {
+			t.Errorf("%s: expected only the copy to be case-insensitive", ExprOf(test.r))
+		}
+		if folded.Hash() != test.r.Hash() {
+			t.Errorf("expected copy to keep hash %s, got %s", test.r.Hash(), folded.Hash())
+		}
+		if req := httptest.NewRequest(http.MethodGet, test.path, nil); test.r.MatchAndUpdateContext(rctx.PrepareRequestContext(req, 1)) != nil {
+			t.Errorf("%s: expected original route to stay case-sensitive", ExprOf(test.r))
+		}
+		if req := httptest.NewRequest(http.MethodGet, test.path, nil); folded.MatchAndUpdateContext(rctx.PrepareRequestContext(req, 1)) == nil {
+			t.Errorf("%s: expected copy to match %s", ExprOf(test.r), test.path)
+		}
+		if want, _ := GetTimeout(test.r); want != 0 {
+			if d, ok := GetTimeout(folded); !ok || d != want {
+				t.Errorf("%s: expected copy to keep timeout %v, got %v", ExprOf(test.r), want, d)
+			}
+		}
+	}
+	if r := Declare(http.MethodGet, "/Users", CaseInsensitive()); Fold(r) != r {
+		t.Error("expected case-insensitive route to be returned as is")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	r := Declare(http.MethodGet, "/reports", Timeout(time.Minute))
+	if d, ok := GetTimeout(r); !ok || d != time.Minute {
+		t.Errorf("expected timeout of 1m, got %v (%v)", d, ok)
+	}
+	if d, ok := GetTimeout(Declare(http.MethodGet, "/reports", Timeout(0))); !ok || d != 0 {
+		t.Errorf("expected disabled timeout, got %v (%v)", d, ok)
+	}
+	if _, ok := GetTimeout(Declare(http.MethodGet, "/reports")); ok {
+		t.Error("expected route without a timeout")
+	}
+	if _, err := New(http.MethodGet, "/reports", Timeout(-time.Second)); err == nil {
+		t.Error("expected negative timeout to fail")
+	}
+}
@@ -1,5 +1,12 @@
 package route
 
+import (
+	"regexp/syntax"
+	"strings"
+
+	"github.com/decentplatforms/matcha/pkg/path"
+)
+
 // Get the number of params that need to be allocated for this route.
 func NumParams(r Route) int {
 	ct := 0
@@ -11,3 +18,228 @@ func NumParams(r Route) int {
 	}
 	return ct
 }
+
+// Get the names of the params set by this route, in order.
+func Params(r Route) []string {
+	var names []string
+	for _, p := range r.Parts() {
+		if pp, ok := p.(paramPart); ok && pp.ParameterName() != "" {
+			names = append(names, pp.ParameterName())
+		}
+	}
+	return names
+}
+
+// Get the values set on a route, if the route is implemented in this package.
+func valuesOf(r Route) map[any]any {
+	switch route := r.(type) {
+	case *defaultRoute:
+		return route.values
+	case *partialRoute:
+		return route.values
+	default:
+		return nil
+	}
+}
+
+// partCovers checks if every token matched by b is also matched by a.
+// Regex parts only cover static parts whose values they match and regex parts with the same regex, since regex
+// containment isn't checked.
+func partCovers(a, b Part) bool {
+	switch pa := a.(type) {
+	case *wildcardPart:
+		return true
+	case *stringPart:
+		// Folding parts match more tokens, so they're only covered by other folding parts.
+		pb, ok := b.(*stringPart)
+		if !ok || (pb.fold && !pa.fold) {
+			return false
+		}
+		return pa.val == pb.val || (pa.fold && strings.EqualFold(pa.val, pb.val))
+	case *regexPart:
+		switch pb := b.(type) {
+		case *stringPart:
+			return !pb.fold && pa.Match(nil, pb.val)
+		case *regexPart:
+			return pa.expr.String() == pb.expr.String()
+		}
+		return false
+	default:
+		return a.Eq(b)
+	}
+}
+
+// Check if every request path matched by b is also matched by a, ignoring methods and requirements.
+// Covers is conservative: it may return false for routes that do cover each other, like routes with different
+// regex that match the same tokens, but never returns true for routes that don't.
+func Covers(a, b Route) bool {
+	pa, pb := a.Parts(), b.Parts()
+	var aEnd, bEnd *partialEndPart
+	if len(pa) > 0 {
+		aEnd, _ = pa[len(pa)-1].(*partialEndPart)
+	}
+	if len(pb) > 0 {
+		bEnd, _ = pb[len(pb)-1].(*partialEndPart)
+	}
+	if bEnd != nil {
+		pb = pb[:len(pb)-1]
+	}
+	if aEnd == nil {
+		// a matches a fixed number of tokens, so b must too.
+		if bEnd != nil || len(pa) != len(pb) {
+			return false
+		}
+		for i := range pa {
+			if !partCovers(pa[i], pb[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	// a matches its fixed parts, then any number of tokens matching its partial part.
+	pa = pa[:len(pa)-1]
+	if len(pb) < len(pa) {
+		return false
+	}
+	for i := range pa {
+		if !partCovers(pa[i], pb[i]) {
+			return false
+		}
+	}
+	for _, p := range pb[len(pa):] {
+		if !partCovers(aEnd.subPart, p) {
+			return false
+		}
+	}
+	return bEnd == nil || partCovers(aEnd.subPart, bEnd.subPart)
+}
+
+// canMatch checks if a regex can match some string that doesn't contain "/".
+// Anchors and word boundaries are assumed to be satisfiable.
+func canMatch(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return false
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if r == '/' {
+				return false
+			}
+		}
+		return true
+	case syntax.OpCharClass:
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if lo, hi := re.Rune[i], re.Rune[i+1]; lo != '/' || hi != '/' {
+				return true
+			}
+		}
+		return false
+	case syntax.OpCapture, syntax.OpPlus:
+		return canMatch(re.Sub[0])
+	case syntax.OpRepeat:
+		return re.Min == 0 || canMatch(re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if !canMatch(sub) {
+				return false
+			}
+		}
+		return true
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if canMatch(sub) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// Check if a Part can match any token.
+// Regex parts can't match any token if their regex requires a "/", since tokens never contain one after their
+// leading slash, or if it can't match anything at all. Other parts can always match some token.
+func CanMatch(p Part) bool {
+	switch part := p.(type) {
+	case *regexPart:
+		re, err := syntax.Parse(part.expr.String(), syntax.Perl)
+		if err != nil {
+			return true
+		}
+		return canMatch(re.Simplify())
+	case *partialEndPart:
+		return CanMatch(part.subPart)
+	default:
+		return true
+	}
+}
+
+// foldParts makes the static parts of a route match case-insensitively.
+func foldParts(r Route) {
+	for _, p := range r.Parts() {
+		if pep, ok := p.(*partialEndPart); ok {
+			p = pep.subPart
+		}
+		if sp, ok := p.(*stringPart); ok {
+			sp.fold = true
+		}
+	}
+}
+
+// copyParts copies the static parts of a route, so they can be folded without changing the original route.
+// Other parts are shared.
+func copyParts(ps []Part) []Part {
+	c := make([]Part, len(ps))
+	for i, p := range ps {
+		switch p := p.(type) {
+		case *stringPart:
+			c[i] = &stringPart{val: p.val, fold: p.fold}
+		case *partialEndPart:
+			if sp, ok := p.subPart.(*stringPart); ok {
+				c[i] = &partialEndPart{param: p.param, subPart: &stringPart{val: sp.val, fold: sp.fold}}
+			} else {
+				c[i] = p
+			}
+		default:
+			c[i] = p
+		}
+	}
+	return c
+}
+
+// copyValues copies the values set on a route.
+func copyValues(values map[any]any) map[any]any {
+	c := make(map[any]any, len(values))
+	for key, value := range values {
+		c[key] = value
+	}
+	return c
+}
+
+// Casing gives a request path matched by r with its static segments in the casing of r's expression.
+// Other segments keep their casing, and consecutive slashes are collapsed.
+// Returns false if the path is already in the casing of r.
+func Casing(r Route, reqPath string) (string, bool) {
+	ps := r.Parts()
+	var sb strings.Builder
+	changed := false
+	i := 0
+	var token string
+	for next := 0; next != -1; i++ {
+		token, next = path.Next(reqPath, next)
+		p := ps[len(ps)-1]
+		if i < len(ps) {
+			p = ps[i]
+		}
+		if pep, ok := p.(*partialEndPart); ok {
+			p = pep.subPart
+		}
+		if sp, ok := p.(*stringPart); ok && sp.val != token {
+			token = sp.val
+			changed = true
+		}
+		sb.WriteString(token)
+	}
+	return sb.String(), changed
+}
@@ -2,6 +2,7 @@ package route
 
 import (
 	"net/http"
+	"reflect"
 	"testing"
 )
 
@@ -23,3 +24,104 @@ func TestNumParams(t *testing.T) {
 		t.Errorf("expected 1 params, got %d", np)
 	}
 }
+
+func TestParams(t *testing.T) {
+	r1 := Declare(http.MethodGet, "/static/route")
+	if ps := Params(r1); len(ps) != 0 {
+		t.Errorf("expected no params, got %v", ps)
+	}
+	r2 := Declare(http.MethodGet, "/[wc1]{.+}/{.+}/[wc2]/[wc3]+")
+	if ps := Params(r2); !reflect.DeepEqual(ps, []string{"wc1", "wc2", "wc3"}) {
+		t.Errorf("expected [wc1 wc2 wc3], got %v", ps)
+	}
+	if expr := ExprOf(r2); expr != "/[wc1]{.+}/{.+}/[wc2]/[wc3]+" {
+		t.Errorf("expected original expression, got %s", expr)
+	}
+}
+
+func TestCovers(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"/static/route", "/static/route", true},
+		{"/static/route", "/static/other", false},
+		{"/static/[wc]", "/static/route", true},
+		{"/static/route", "/static/[wc]", false},
+		{"/[wc1]/[wc2]", "/static/{.+}", true},
+		{"/[wc]", "/static/route", false},
+		{"/[id]{[0-9]+}", "/123", true},
+		{"/[id]{[0-9]+}", "/abc", false},
+		{"/[id]{[0-9]+}", "/{[0-9]+}", true},
+		{"/[id]{[0-9]+}", "/[n]{[0-9]{1,3}}", false},
+		{"/files/+", "/files", true},
+		{"/files/+", "/files/a/b/c", true},
+		{"/files/+", "/files/[f]+", true},
+		{"/files/+", "/other/a", false},
+		{"/files/[f]{.+\\.txt}+", "/files/a.txt/b.txt", true},
+		{"/files/[f]{.+\\.txt}+", "/files/a.txt/b.png", false},
+		{"/files/[f]{.+\\.txt}+", "/files/+", false},
+		{"/files/a/+", "/files/+", false},
+		{"/files", "/files/+", false},
+		{"/files", "/Files", false},
+	}
+	for _, test := range tests {
+		a := Declare(http.MethodGet, test.a)
+		b := Declare(http.MethodGet, test.b)
+		if got := Covers(a, b); got != test.want {
+			t.Errorf("Covers(%s, %s): expected %t, got %t", test.a, test.b, test.want, got)
+		}
+	}
+	fold := Declare(http.MethodGet, "/Users", CaseInsensitive())
+	if !Covers(fold, Declare(http.MethodGet, "/users")) {
+		t.Error("expected case-insensitive route to cover other casings")
+	}
+	if Covers(Declare(http.MethodGet, "/Users"), fold) {
+		t.Error("expected case-sensitive route not to cover case-insensitive route")
+	}
+}
+
+func TestCanMatch(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"/static", true},
+		{"/[wc]", true},
+		{"/{[0-9]+}", true},
+		{"/{a\\x2fb}", false},
+		{"/{a\\x2fb|c}", true},
+		{"/{[\\x2f]+}", false},
+		{"/{a*\\x2f?}", true},
+		{"/{[^\\s\\S]}", false},
+		{"/[f]{x\\x2fy}+", false},
+	}
+	for _, test := range tests {
+		r := Declare(http.MethodGet, test.expr)
+		if got := CanMatch(r.Parts()[0]); got != test.want {
+			t.Errorf("CanMatch(%s): expected %t, got %t", test.expr, test.want, got)
+		}
+	}
+}
+
+func TestCasing(t *testing.T) {
+	r := Declare(http.MethodGet, "/Users/[id]/Files/[path]+", CaseInsensitive())
+	tests := []struct {
+		path    string
+		want    string
+		changed bool
+	}{
+		{"/Users/AbC/Files/a/B", "/Users/AbC/Files/a/B", false},
+		{"/users/AbC/FILES/a/B", "/Users/AbC/Files/a/B", true},
+		{"/users//AbC/files", "/Users/AbC/Files", true},
+	}
+	for _, test := range tests {
+		if got, changed := Casing(r, test.path); got != test.want || changed != test.changed {
+			t.Errorf("Casing(%s): expected %s %t, got %s %t", test.path, test.want, test.changed, got, changed)
+		}
+	}
+	static := Declare(http.MethodGet, "/Static/Files+", CaseInsensitive())
+	if got, _ := Casing(static, "/static/files/FILES"); got != "/Static/Files/Files" {
+		t.Errorf("expected partial static parts to be cased, got %s", got)
+	}
+}
@@ -0,0 +1,178 @@
+package router
+
+import (
+	"hash/maphash"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/decentplatforms/matcha/pkg/rctx"
+	"github.com/decentplatforms/matcha/pkg/route"
+	"github.com/decentplatforms/matcha/pkg/tree"
+)
+
+// matchCache is a bounded cache of the routes matched by request method and path.
+// It's split into shards by path, so requests to different paths rarely share a lock, and each shard evicts with the
+// CLOCK algorithm: a hit only sets the entry's referenced bit under a read lock, and once the shard is full, a new
+// entry replaces the first one the clock hand finds that hasn't been referenced since the hand last passed it.
+// It's safe for concurrent use, and a nil matchCache caches nothing.
+type matchCache struct {
+	size   int
+	seed   maphash.Seed
+	shards []cacheShard
+}
+
+// cacheShard is a CLOCK cache of up to len(ring) entries, once it fills.
+type cacheShard struct {
+	mu      sync.RWMutex
+	entries map[matchKey]*matchEntry
+	ring    []*matchEntry
+	hand    int
+}
+
+type matchKey struct {
+	method string
+	path   string
+}
+
+type matchEntry struct {
+	key        matchKey
+	leaf_id    int
+	referenced atomic.Bool
+}
+
+const (
+	// Shards hold at least minShardSize entries, so small caches aren't split into shards too small to be useful.
+	minShardSize = 32
+	maxShards    = 16
+)
+
+// Create a matchCache that holds up to size entries.
+func newMatchCache(size int) *matchCache {
+	n := 1
+	for n < maxShards && size/(n*2) >= minShardSize {
+		n *= 2
+	}
+	c := &matchCache{
+		size:   size,
+		seed:   maphash.MakeSeed(),
+		shards: make([]cacheShard, n),
+	}
+	for i := range c.shards {
+		// The first size % n shards hold an extra entry, so the shards hold size entries in total.
+		capacity := size / n
+		if i < size%n {
+			capacity++
+		}
+		c.shards[i].init(capacity)
+	}
+	return c
+}
+
+// init empties the shard, to hold up to capacity entries.
+func (s *cacheShard) init(capacity int) {
+	s.entries = make(map[matchKey]*matchEntry, capacity)
+	s.ring = make([]*matchEntry, 0, capacity)
+	s.hand = 0
+}
+
+// shard gets the shard that caches a path.
+func (c *matchCache) shard(rpath string) *cacheShard {
+	if len(c.shards) == 1 {
+		return &c.shards[0]
+	}
+	return &c.shards[maphash.String(c.seed, rpath)&uint64(len(c.shards)-1)]
+}
+
+// get gets the leaf ID of the route cached for a method and path.
+func (c *matchCache) get(method, rpath string) (int, bool) {
+	if c == nil {
+		return tree.NO_LEAF_ID, false
+	}
+	s := c.shard(rpath)
+	s.mu.RLock()
+	e, ok := s.entries[matchKey{method, rpath}]
+	if !ok {
+		s.mu.RUnlock()
+		return tree.NO_LEAF_ID, false
+	}
+	leaf_id := e.leaf_id
+	// Entries that are hit often are usually referenced already; loading first avoids writing to them on every hit.
+	if !e.referenced.Load() {
+		e.referenced.Store(true)
+	}
+	s.mu.RUnlock()
+	return leaf_id, true
+}
+
+// add caches the leaf ID of the route matched for a method and path.
+func (c *matchCache) add(method, rpath string, leaf_id int) {
+	if c == nil {
+		return
+	}
+	s := c.shard(rpath)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := matchKey{method, rpath}
+	if e, ok := s.entries[key]; ok {
+		e.leaf_id = leaf_id
+		e.referenced.Store(true)
+		return
+	}
+	e := &matchEntry{key: key, leaf_id: leaf_id}
+	s.entries[key] = e
+	if len(s.ring) < cap(s.ring) {
+		s.ring = append(s.ring, e)
+		return
+	}
+	// Give referenced entries a second chance; this ends within one turn of the clock, since it clears them.
+	for s.ring[s.hand].referenced.Load() {
+		s.ring[s.hand].referenced.Store(false)
+		s.hand = (s.hand + 1) % len(s.ring)
+	}
+	delete(s.entries, s.ring[s.hand].key)
+	s.ring[s.hand] = e
+	s.hand = (s.hand + 1) % len(s.ring)
+}
+
+// reset drops every entry in the cache.
+func (c *matchCache) reset() {
+	if c == nil {
+		return
+	}
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		s.init(cap(s.ring))
+		s.mu.Unlock()
+	}
+}
+
+// empty gets a new cache of the same size, or nil if c is nil.
+func (c *matchCache) empty() *matchCache {
+	if c == nil {
+		return nil
+	}
+	return newMatchCache(c.size)
+}
+
+// match matches a request to the table's routes like RouteTree.Bind, checking the match cache first.
+// A match is only cached if every request with the same method and path matches the same route: the route has no
+// params to bind and no requirements, and no route with requirements could have matched first.
+func (t *table) match(req *http.Request, ctx *rctx.Context) int {
+	if t.cache == nil {
+		return t.rtree.Bind(req, ctx)
+	}
+	if leaf_id, ok := t.cache.get(req.Method, req.URL.Path); ok {
+		return leaf_id
+	}
+	leaf_id := t.rtree.Bind(req, ctx)
+	if leaf_id == tree.NO_LEAF_ID {
+		return leaf_id
+	}
+	r := t.routes[leaf_id]
+	if route.NumParams(r) == 0 && len(r.Required()) == 0 && t.rtree.MatchPath(req.Method, req.URL.Path) == leaf_id {
+		t.cache.add(req.Method, req.URL.Path, leaf_id)
+	}
+	return leaf_id
+}
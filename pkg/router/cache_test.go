@@ -0,0 +1,193 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/decentplatforms/matcha/pkg/route"
+	"github.com/decentplatforms/matcha/pkg/route/require"
+)
+
+func TestMatchCache(t *testing.T) {
+	rt := Declare(
+		Default(),
+		WithMatchCache(3),
+		ImplicitHead(),
+		HandleFunc(http.MethodGet, "/health", okHandler("health")),
+		HandleFunc(http.MethodGet, "/users/[id]", okHandler("user")),
+		HandleRoute(route.Declare(http.MethodGet, "/status", route.Require(require.Hosts("internal.com"))), okHandler("internal")),
+		HandleFunc(http.MethodGet, "/status", okHandler("status")),
+		HandleRoute(route.Declare(http.MethodGet, "/admin", route.Require(require.Hosts("internal.com"))), okHandler("admin")),
+		HandleFunc(http.MethodGet, "/assets/app.js", okHandler("js")),
+		HandleFunc(http.MethodGet, "/assets/app.css", okHandler("css")),
+	)
+	cache := rt.(*defaultRouter).tbl.Load().cache
+	tests := []struct {
+		method string
+		target string
+		body   string
+		cached bool
+	}{
+		{http.MethodGet, "/health", "health", true},
+		{http.MethodHead, "/health", "", true},
+		{http.MethodGet, "/users/12", "user", false},
+		// Matches that depend on the request's host aren't cached, even if the route that matches doesn't.
+		{http.MethodGet, "http://internal.com/status", "internal", false},
+		{http.MethodGet, "/status", "status", false},
+		{http.MethodGet, "http://internal.com/admin", "admin", false},
+		{http.MethodGet, "/assets/app.js", "js", true},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(test.method, test.target, nil)
+		rt.ServeHTTP(w, req)
+		if w.Body.String() != test.body {
+			t.Errorf("%s %s: expected %q, got %q", test.method, test.target, test.body, w.Body.String())
+		}
+		if _, ok := cache.get(test.method, req.URL.Path); ok != test.cached {
+			t.Errorf("%s %s: expected cached to be %t", test.method, test.target, test.cached)
+		}
+	}
+	// Cached requests are served the same way.
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+		if w.Body.String() != "health" {
+			t.Errorf("expected cached route to serve health, got %q", w.Body.String())
+		}
+	}
+	// Once the cache is full, new paths replace old ones.
+	rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/assets/app.css", nil))
+	if _, ok := cache.get(http.MethodGet, "/assets/app.css"); !ok {
+		t.Error("expected new path to be cached in a full cache")
+	}
+	// Changes to the Router drop the cache.
+	if err := rt.Disable(http.MethodGet, "/health", http.StatusServiceUnavailable); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected disabled route to respond 503, got %d", w.Code)
+	}
+	if err := rt.Remove(http.MethodGet, "/health"); err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected removed route to respond 404, got %d", w.Code)
+	}
+	if err := WithMatchCache(-1)(Default()); err == nil {
+		t.Error("expected negative cache size to fail")
+	}
+}
+
+func TestMatchCacheEviction(t *testing.T) {
+	c := newMatchCache(3)
+	c.add(http.MethodGet, "/a", 0)
+	c.add(http.MethodGet, "/b", 1)
+	c.add(http.MethodGet, "/c", 2)
+	c.add(http.MethodGet, "/c", 3)
+	if id, ok := c.get(http.MethodGet, "/c"); !ok || id != 3 {
+		t.Errorf("expected re-added path to have leaf 3, got %d", id)
+	}
+	// Paths hit since the clock hand last passed them get a second chance, so /a stays and /b is dropped.
+	if _, ok := c.get(http.MethodGet, "/a"); !ok {
+		t.Fatal("expected /a to be cached")
+	}
+	c.add(http.MethodGet, "/d", 4)
+	if _, ok := c.get(http.MethodGet, "/b"); ok {
+		t.Error("expected unreferenced path to be dropped")
+	}
+	for i, p := range []string{"/a", "/c", "/d"} {
+		if _, ok := c.get(http.MethodGet, p); !ok {
+			t.Errorf("%d: expected %s to be cached", i, p)
+		}
+	}
+	c.reset()
+	if _, ok := c.get(http.MethodGet, "/a"); ok {
+		t.Error("expected reset to drop every path")
+	}
+	// Large caches are split into shards, which hold size entries in total.
+	for _, size := range []int{1, 31, 64, 100, 1000, 5000} {
+		c := newMatchCache(size)
+		total := 0
+		for i := range c.shards {
+			total += cap(c.shards[i].ring)
+		}
+		if total != size {
+			t.Errorf("size %d: expected shards to hold %d entries, got %d", size, size, total)
+		}
+		for i := 0; i < 2*size; i++ {
+			c.add(http.MethodGet, fmt.Sprintf("/%d", i), i)
+		}
+		cached := 0
+		for i := range c.shards {
+			cached += len(c.shards[i].entries)
+		}
+		if cached > size {
+			t.Errorf("size %d: expected at most %d entries, got %d", size, size, cached)
+		}
+	}
+}
+
+func TestMatchCacheCaseInsensitive(t *testing.T) {
+	rt := Declare(
+		Default(),
+		WithMatchCache(8),
+		CaseInsensitive(true),
+		HandleFunc(http.MethodGet, "/Health", okHandler("health")),
+	)
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/Health" {
+			t.Errorf("expected redirect to /Health, got %d %q", w.Code, w.Header().Get("Location"))
+		}
+		w = httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/Health", nil))
+		if w.Body.String() != "health" {
+			t.Errorf("expected /Health to serve health, got %q", w.Body.String())
+		}
+	}
+}
+
+func TestMatchCacheConcurrent(t *testing.T) {
+	rt := Declare(
+		Concurrent(),
+		WithMatchCache(4),
+		HandleFunc(http.MethodGet, "/static", okHandler("static")),
+	)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static", nil))
+				if w.Code != http.StatusOK {
+					t.Errorf("expected 200, got %d", w.Code)
+					return
+				}
+				rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, fmt.Sprintf("/plugin/%d", j), nil))
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		if err := rt.HandleFunc(http.MethodGet, fmt.Sprintf("/plugin/%d", i), okHandler("plugin")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	// Each change swaps in a table with an empty cache, so new routes are matched.
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/plugin/49", nil))
+	if w.Body.String() != "plugin" {
+		t.Errorf("expected plugin, got %q", w.Body.String())
+	}
+}
@@ -1,9 +1,12 @@
 package router
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/decentplatforms/matcha/pkg/cors"
+	"github.com/decentplatforms/matcha/pkg/httperr"
 	"github.com/decentplatforms/matcha/pkg/middleware"
 	"github.com/decentplatforms/matcha/pkg/route"
 )
@@ -11,13 +14,22 @@ import (
 // ConfigFuncs run on Routers, usually to add a route or attach middleware.
 type ConfigFunc func(rt Router) error
 
+// asDefault gets the default Router implementation behind rt, for ConfigFuncs that set options
+// specific to it.
+func asDefault(rt Router, option string) (*defaultRouter, error) {
+	drt, ok := rt.(*defaultRouter)
+	if !ok {
+		return nil, fmt.Errorf("%s is not supported by router %T", option, rt)
+	}
+	return drt, nil
+}
+
 // Add a Route for the Router to handle.
 //
 // AddRoute was deprecated in v1.2.0. Use HandleRoute instead.
 func WithRoute(r route.Route, h http.Handler) ConfigFunc {
 	return func(rt Router) error {
-		rt.AddRoute(r, h)
-		return nil
+		return handleRoute(rt, r, h)
 	}
 }
 
@@ -33,17 +45,34 @@ func HandleFunc(method, path string, h http.HandlerFunc) ConfigFunc {
 	}
 }
 
+// Handle a method and path with a handler that can return an error.
+// See Router.HandleE.
+func HandleE(method, path string, h httperr.HandlerFunc) ConfigFunc {
+	return func(rt Router) error {
+		return rt.HandleE(method, path, h)
+	}
+}
+
 func HandleRoute(r route.Route, h http.Handler) ConfigFunc {
 	return func(rt Router) error {
-		rt.HandleRoute(r, h)
-		return nil
+		return handleRoute(rt, r, h)
 	}
 }
 
 func HandleRouteFunc(r route.Route, h http.HandlerFunc) ConfigFunc {
 	return func(rt Router) error {
-		rt.HandleRouteFunc(r, h)
-		return nil
+		if h == nil {
+			return handleRoute(rt, r, nil)
+		}
+		return handleRoute(rt, r, h)
+	}
+}
+
+// Create a group of routes under a prefix on the Router.
+// See Router.Group.
+func Group(prefix string, fn func(g Router), cfs ...route.ConfigFunc) ConfigFunc {
+	return func(rt Router) error {
+		return rt.Group(prefix, fn, cfs...)
 	}
 }
 
@@ -55,9 +84,31 @@ func WithNotFound(h http.Handler) ConfigFunc {
 	}
 }
 
+// Add a handler for requests under prefix that are not handled by any other route in the Router
+func WithNotFoundAt(prefix string, h http.Handler) ConfigFunc {
+	return func(rt Router) error {
+		return rt.AddNotFoundAt(prefix, h)
+	}
+}
+
+// Add a handler for requests with a path that is handled by the Router, but not with the request method.
+// The Allow header is set on the response before the handler is called.
+func WithMethodNotAllowed(h http.Handler) ConfigFunc {
+	return func(rt Router) error {
+		rt.AddMethodNotAllowed(h)
+		return nil
+	}
+}
+
 // Give a default set of CORS headers.
+// If the Router answers OPTIONS requests automatically, these options are used for preflight responses on
+// routes that don't have their own.
 func DefaultCORSHeaders(aco *cors.AccessControlOptions) ConfigFunc {
 	return func(rt Router) error {
+		if cd, ok := rt.(corsDefaulter); ok {
+			cd.defaultCORS(aco)
+			return nil
+		}
 		rt.Attach(cors.CORSMiddleware(aco))
 		return nil
 	}
@@ -82,6 +133,252 @@ func PreflightCORS(expr string, aco *cors.AccessControlOptions) ConfigFunc {
 	}
 }
 
+// Automatically answer OPTIONS requests to any path handled by the Router.
+// Responses have status 204 (No Content) and an Allow header listing the methods registered for the path.
+// Preflight requests additionally get CORS headers from the options attached to the requested route with
+// route.CORSHeaders, or the Router's DefaultCORSHeaders, with Access-Control-Allow-Methods limited to
+// the registered methods. Explicit OPTIONS routes, like those from PreflightCORS, take priority.
+func AutoOptions() ConfigFunc {
+	return func(rt Router) error {
+		drt, err := asDefault(rt, "AutoOptions")
+		if err != nil {
+			return err
+		}
+		drt.edit(func(t *table) {
+			t.autoOptions = true
+		})
+		return nil
+	}
+}
+
+// Serve HEAD requests with GET routes when no HEAD route matches.
+// The route's params, middleware, and requirements all apply as they would for a GET request. The response
+// keeps the headers the handler sets, but drops its body; if the handler doesn't set Content-Length,
+// it is set to the length of the dropped body.
+func ImplicitHead() ConfigFunc {
+	return func(rt Router) error {
+		drt, err := asDefault(rt, "ImplicitHead")
+		if err != nil {
+			return err
+		}
+		drt.edit(func(t *table) {
+			t.rtree.SetFallback(http.MethodHead, http.MethodGet)
+		})
+		return nil
+	}
+}
+
+// Set how the Router treats request paths that aren't in canonical form, like paths with consecutive slashes,
+// dot segments, or a trailing slash the matching route doesn't expect. See PathMode.
+func WithPathMode(mode PathMode) ConfigFunc {
+	return func(rt Router) error {
+		drt, err := asDefault(rt, "WithPathMode")
+		if err != nil {
+			return err
+		}
+		if mode < PathLenient || mode > PathRedirect {
+			return fmt.Errorf("invalid path mode %d", mode)
+		}
+		drt.edit(func(t *table) {
+			t.pathMode = mode
+		})
+		return nil
+	}
+}
+
+// Match the static parts of routes registered after the option case-insensitively, as with
+// route.CaseInsensitive. Params keep the casing of the request.
+// If redirect is true, requests matched by a case-insensitive route with different casing are redirected to the
+// path with the casing of the route, like redirects in PathRedirect mode; this includes routes made
+// case-insensitive with route.CaseInsensitive.
+func CaseInsensitive(redirect bool) ConfigFunc {
+	return func(rt Router) error {
+		drt, err := asDefault(rt, "CaseInsensitive")
+		if err != nil {
+			return err
+		}
+		drt.edit(func(t *table) {
+			t.fold = true
+			t.foldRedirect = redirect
+		})
+		return nil
+	}
+}
+
+// Set the number of params allocated for each request, including params set by middleware and handlers with
+// rctx.SetParam. Requests always get enough params for the route they match, even if it has more than n.
+// The default is rctx.DefaultMaxParams.
+//
+// Params set past the maximum are dropped, and rctx.SetParam returns an error wrapping rctx.ErrOverCapacity;
+// use GrowParams to allocate more params instead.
+func WithMaxParams(n int) ConfigFunc {
+	return func(rt Router) error {
+		drt, err := asDefault(rt, "WithMaxParams")
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("invalid max params %d", n)
+		}
+		drt.edit(func(t *table) {
+			t.maxParams = n
+		})
+		return nil
+	}
+}
+
+// Let the params of each request grow past the maximum set with WithMaxParams, instead of dropping params
+// set past it. See rctx.GrowParams.
+func GrowParams() ConfigFunc {
+	return func(rt Router) error {
+		drt, err := asDefault(rt, "GrowParams")
+		if err != nil {
+			return err
+		}
+		drt.edit(func(t *table) {
+			t.growParams = true
+		})
+		return nil
+	}
+}
+
+// Match routes by specificity instead of registration order.
+// At each path segment, static parts are tried before regex parts, regex parts before wildcards, and wildcards
+// before partials; routes that are equally specific are matched in registration order. This applies to routes
+// registered before the option too.
+func MostSpecific() ConfigFunc {
+	return func(rt Router) error {
+		drt, err := asDefault(rt, "MostSpecific")
+		if err != nil {
+			return err
+		}
+		drt.edit(func(t *table) {
+			t.rtree.SetPriority(true)
+		})
+		return nil
+	}
+}
+
+// Set the handler for errors returned by handlers registered with HandleE, and errors reported with
+// httperr.Report by middleware, like middleware.ExpectHeader.
+// Errors are written with httperr.Write by default. The handler is also used by mounted Routers, unless they
+// set their own.
+func WithErrorHandler(h httperr.ErrorHandler) ConfigFunc {
+	return func(rt Router) error {
+		drt, err := asDefault(rt, "WithErrorHandler")
+		if err != nil {
+			return err
+		}
+		drt.edit(func(t *table) {
+			t.errors = h
+		})
+		return nil
+	}
+}
+
+// Recover from panics in handlers and middleware, and pass them to fn to respond.
+// If fn is nil, requests that panic get 500 Internal Server Error. Request contexts are returned to the pool
+// before fn is called, and panics with http.ErrAbortHandler aren't recovered.
+func WithRecovery(fn RecoveryFunc) ConfigFunc {
+	return func(rt Router) error {
+		drt, err := asDefault(rt, "WithRecovery")
+		if err != nil {
+			return err
+		}
+		if fn == nil {
+			fn = recoverInternalError
+		}
+		drt.edit(func(t *table) {
+			t.recovery = fn
+		})
+		return nil
+	}
+}
+
+// Limit how long routes have to respond, unless they set their own timeout with route.Timeout.
+// Matched requests get a context deadline of d; if the route isn't done by then, the Router responds with the
+// timeout handler, and further writes by the route fail with http.ErrHandlerTimeout. Responses of routes with
+// timeouts are buffered until they finish, so they can't be streamed. A timeout of 0 disables the default.
+// Routes with timeouts run in their own goroutine; panics in them are passed to the RecoveryFunc with the stack
+// of that goroutine, or panicked again as they were if the Router has no RecoveryFunc.
+func WithTimeout(d time.Duration) ConfigFunc {
+	return func(rt Router) error {
+		drt, err := asDefault(rt, "WithTimeout")
+		if err != nil {
+			return err
+		}
+		if d < 0 {
+			return fmt.Errorf("invalid timeout %s", d)
+		}
+		drt.edit(func(t *table) {
+			t.timeout = d
+		})
+		return nil
+	}
+}
+
+// Respond to requests that time out with h.
+// If h is nil, requests that time out get 503 Service Unavailable.
+func WithTimeoutHandler(h http.Handler) ConfigFunc {
+	return func(rt Router) error {
+		drt, err := asDefault(rt, "WithTimeoutHandler")
+		if err != nil {
+			return err
+		}
+		if h == nil {
+			h = http.HandlerFunc(timeoutServiceUnavailable)
+		}
+		drt.edit(func(t *table) {
+			t.onTimeout = h
+		})
+		return nil
+	}
+}
+
+// Cache the routes matched by up to size request paths, so requests to recently used paths skip matching.
+// Only matches that don't depend on anything but the request's method and path are cached: routes with no params
+// and no requirements, like health checks and static files, matched without trying a route with requirements first.
+// The cache is dropped whenever the Router changes.
+// If size is 0, matches aren't cached.
+func WithMatchCache(size int) ConfigFunc {
+	return func(rt Router) error {
+		drt, err := asDefault(rt, "WithMatchCache")
+		if err != nil {
+			return err
+		}
+		if size < 0 {
+			return fmt.Errorf("invalid match cache size %d", size)
+		}
+		drt.edit(func(t *table) {
+			t.cache = nil
+			if size > 0 {
+				t.cache = newMatchCache(size)
+			}
+		})
+		return nil
+	}
+}
+
+// Reject routes that the Router could never serve.
+// In strict mode, registering a route that would be a Problem reported by Validate fails, and the Router is
+// unchanged: Handle, HandleFunc, Mount, and the ConfigFuncs that register routes return the Problem, and
+// HandleRoute, HandleRouteFunc, and AddRoute, which can't return errors, keep it so Validate reports it.
+// Routes registered before the option aren't checked; use Validate to find problems with them.
+//
+// Each registration in strict mode compares the route to the routes matched before it, so it is slower than usual.
+func Strict() ConfigFunc {
+	return func(rt Router) error {
+		drt, err := asDefault(rt, "Strict")
+		if err != nil {
+			return err
+		}
+		drt.edit(func(t *table) {
+			t.strict = true
+		})
+		return nil
+	}
+}
+
 // Attach generic middleware to the Router
 func WithMiddleware(mws ...middleware.Middleware) ConfigFunc {
 	return func(rt Router) error {
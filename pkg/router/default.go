@@ -3,7 +3,11 @@ package router
 import (
 	"errors"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/decentplatforms/matcha/pkg/httperr"
 	"github.com/decentplatforms/matcha/pkg/middleware"
 	"github.com/decentplatforms/matcha/pkg/path"
 	"github.com/decentplatforms/matcha/pkg/rctx"
@@ -12,30 +16,24 @@ import (
 )
 
 type defaultRouter struct {
-	mws       []middleware.Middleware
-	routes    map[string]map[int]route.Route
-	rtree     *tree.RouteTree
-	handlers  map[string]map[int]http.Handler
-	notfound  http.Handler
-	maxParams int
+	tbl        atomic.Pointer[table]
+	mu         sync.Mutex
+	concurrent bool
 }
 
 func Default() *defaultRouter {
-	return &defaultRouter{
-		mws:       make([]middleware.Middleware, 0),
-		routes:    make(map[string]map[int]route.Route),
-		rtree:     tree.New(),
-		handlers:  make(map[string]map[int]http.Handler),
-		notfound:  http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) }),
-		maxParams: rctx.DefaultMaxParams,
-	}
+	rt := &defaultRouter{}
+	rt.tbl.Store(newTable())
+	return rt
 }
 
 // Attach middleware to the router.
 //
 // See interface Router.
 func (rt *defaultRouter) Attach(mws ...middleware.Middleware) {
-	rt.mws = append(rt.mws, mws...)
+	rt.edit(func(t *table) {
+		t.mws = append(t.mws, mws...)
+	})
 }
 
 // Add a route to the router.
@@ -44,23 +42,34 @@ func (rt *defaultRouter) Attach(mws ...middleware.Middleware) {
 //
 // See interface Router.
 func (rt *defaultRouter) AddRoute(r route.Route, h http.Handler) {
-	register(rt, r, h)
+	rt.handleOrReject(r, h)
 }
 
-func register(rt *defaultRouter, r route.Route, h http.Handler) {
-	id := rt.rtree.Add(r)
-	if rt.routes[r.Method()] == nil {
-		rt.routes[r.Method()] = make(map[int]route.Route)
-	}
-	rt.routes[r.Method()][id] = r
-	if rt.handlers[r.Method()] == nil {
-		rt.handlers[r.Method()] = make(map[int]http.Handler)
-	}
-	if h != nil {
-		rt.handlers[r.Method()][id] = h
-	} else {
-		rt.handlers[r.Method()][id] = nil
-	}
+// handle adds a route to the router, and returns the route the router stored for it; see register.
+// In strict mode, routes that would cause a Problem aren't added, and the Problem is returned.
+func (rt *defaultRouter) handle(r route.Route, h http.Handler) (route.Route, error) {
+	var stored route.Route
+	var err error
+	rt.edit(func(t *table) {
+		var id int
+		if id, err = t.add(r, h); err == nil {
+			stored = t.routes[id]
+		}
+	})
+	return stored, err
+}
+
+// handleOrReject adds a route to the router, for methods that can't return an error.
+// In strict mode, routes that would cause a Problem aren't added, and the Problem is kept, so Validate reports it.
+func (rt *defaultRouter) handleOrReject(r route.Route, h http.Handler) {
+	rt.edit(func(t *table) {
+		if _, err := t.add(r, h); err != nil {
+			var p Problem
+			if errors.As(err, &p) {
+				t.rejected = append(t.rejected, p)
+			}
+		}
+	})
 }
 
 // Add a route to the router.
@@ -71,8 +80,7 @@ func (rt *defaultRouter) Handle(method, path string, h http.Handler) error {
 	if err != nil {
 		return err
 	}
-	register(rt, r, h)
-	return nil
+	return handleRoute(rt, r, h)
 }
 
 // Add a route to the router.
@@ -84,18 +92,26 @@ func (rt *defaultRouter) HandleFunc(method, path string, h http.HandlerFunc) err
 		return err
 	}
 	if h != nil {
-		register(rt, r, h)
-	} else {
-		register(rt, r, nil)
+		return handleRoute(rt, r, h)
+	}
+	return handleRoute(rt, r, nil)
+}
+
+// Add a route with an error-returning handler to the router.
+//
+// See interface Router.
+func (rt *defaultRouter) HandleE(method, path string, h httperr.HandlerFunc) error {
+	if h == nil {
+		return rt.Handle(method, path, nil)
 	}
-	return nil
+	return rt.Handle(method, path, h)
 }
 
 // Add a route to the router.
 //
 // See interface Router.
 func (rt *defaultRouter) HandleRoute(r route.Route, h http.Handler) {
-	register(rt, r, h)
+	rt.handleOrReject(r, h)
 }
 
 // Add a route to the router.
@@ -103,9 +119,9 @@ func (rt *defaultRouter) HandleRoute(r route.Route, h http.Handler) {
 // See interface Router.
 func (rt *defaultRouter) HandleRouteFunc(r route.Route, h http.HandlerFunc) {
 	if h != nil {
-		register(rt, r, h)
+		rt.handleOrReject(r, h)
 	} else {
-		register(rt, r, nil)
+		rt.handleOrReject(r, nil)
 	}
 }
 
@@ -113,69 +129,147 @@ func (rt *defaultRouter) HandleRouteFunc(r route.Route, h http.HandlerFunc) {
 //
 // See interface Router.
 func (rt *defaultRouter) Mount(rpath string, h http.Handler, methods ...string) error {
-	if len(methods) == 0 {
-		methods = []string{
-			http.MethodPut, http.MethodGet, http.MethodPatch, http.MethodDelete, http.MethodPost,
-			http.MethodOptions, http.MethodHead, http.MethodTrace, http.MethodConnect,
-		}
-	}
-	trim := middleware.TrimPrefix(rpath)
-	rpath = path.MakePartial(rpath, "")
-	validate := route.ConfigFunc(func(r route.Route) error {
-		if route.NumParams(r) > 0 {
-			return errors.New("invalid mount path; must be static strings only")
-		}
-		return nil
-	})
-	for _, method := range methods {
-		r, err := route.New(method, rpath, validate)
-		if err != nil {
-			return err
-		}
-		r.Attach(trim)
-		rt.HandleRoute(r, h)
-	}
-	return nil
+	return rt.MountWith(rpath, h, MountMethods(methods...))
+}
+
+// Mount a handler at path with options.
+//
+// See interface Router.
+func (rt *defaultRouter) MountWith(rpath string, h http.Handler, opts ...MountOption) error {
+	_, err := rt.mount(rpath, h, newMountOptions(opts))
+	return err
+}
+
+// Create a group of routes under a prefix.
+//
+// See interface Router.
+func (rt *defaultRouter) Group(prefix string, fn func(g Router), cfs ...route.ConfigFunc) error {
+	return runGroup(rt, prefix, fn, cfs)
 }
 
 // Set the handler for instances where no route is found.
 //
 // See interface Router.
 func (rt *defaultRouter) AddNotFound(h http.Handler) {
-	rt.notfound = h
+	rt.edit(func(t *table) {
+		t.notfound = h
+	})
+}
+
+// Set the handler for instances where a route matches the path, but not the method.
+//
+// See interface Router.
+func (rt *defaultRouter) AddMethodNotAllowed(h http.Handler) {
+	rt.edit(func(t *table) {
+		t.notallowed = h
+	})
+}
+
+// serveRoute serves a request with the route with leaf ID leaf_id, using ctx, with the params bound while
+// matching the route, as the request context. The context is returned to the pool once the route is done.
+// If the route has a timeout, it is served with serveTimeout.
+func (t *table) serveRoute(w http.ResponseWriter, req *http.Request, leaf_id int, ctx *rctx.Context) {
+	if _, ok := t.mounts[leaf_id]; ok {
+		req = t.inheritNotFound(req)
+	}
+	if d := t.routeTimeout(t.routes[leaf_id]); d > 0 {
+		t.serveTimeout(w, req, leaf_id, ctx, d)
+		return
+	}
+	t.serveHandler(w, req, leaf_id, ctx)
+}
+
+// serveHandler runs the middleware and handler of the route with leaf ID leaf_id.
+// The request context is returned once the route is done, even if it panics.
+func (t *table) serveHandler(w http.ResponseWriter, req *http.Request, leaf_id int, ctx *rctx.Context) {
+	req = rctx.Attach(req, ctx)
+	defer rctx.Release(ctx)
+	rctx.SetParamCapacity(ctx, t.paramCapacity(t.routes[leaf_id]))
+	if t.growParams {
+		rctx.GrowParams(ctx)
+	}
+	req = middleware.ExecuteMiddleware(t.routes[leaf_id].Middleware(), w, req)
+	if req == nil {
+		return
+	}
+	handler := t.handlers[leaf_id]
+	if handler != nil {
+		handler.ServeHTTP(w, req)
+	} else {
+		w.WriteHeader(http.StatusNotImplemented)
+	}
 }
 
 // Implements http.Handler.
 //
 // Serve request using the registered middleware, routes, and handlers.
+// If no route matches the request, but a route for another method matches its path, the request is
+// passed to the MethodNotAllowed handler with the Allow header set. Otherwise, it is passed to the NotFound handler
+// for the longest matching prefix; see AddNotFoundAt.
+// If the Router has a recovery function, panics while serving the request are passed to it.
 // Tree Router organizes routes by their 'prefixes' (first path elements) and serves based on the first
 // path element of the request. Since wildcard and regex parts do not statically evaluate, they are stored as "*".
 func (rt *defaultRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	req = middleware.ExecuteMiddleware(rt.mws, w, req)
+	t := rt.tbl.Load()
+	if t.recovery != nil {
+		rw := &recoveryWriter{ResponseWriter: w}
+		defer t.recoverPanic(rw, req)
+		w = rw
+	}
+	if t.errors != nil {
+		req = req.WithContext(httperr.WithHandler(req.Context(), t.errors))
+	}
+	req = middleware.ExecuteMiddleware(t.mws, w, req)
 	if req == nil {
 		return
 	}
-	leaf_id := rt.rtree.Match(req)
+	if t.pathMode == PathStrict && strings.Contains(req.URL.Path, "//") {
+		t.notFound(req).ServeHTTP(w, req)
+		return
+	}
+	// Params are bound as the request is matched; the context they're bound to is only attached to the request
+	// if a route serves it.
+	ctx := rctx.Acquire(t.bindCapacity())
+	leaf_id := t.match(req, ctx)
+	if t.pathMode == PathRedirect && (leaf_id == tree.NO_LEAF_ID || path.Clean(req.URL.Path) != req.URL.Path) && t.redirect(w, req) {
+		rctx.Release(ctx)
+		return
+	}
 	if leaf_id != tree.NO_LEAF_ID {
-		r := rt.routes[req.Method][leaf_id]
-		req = rctx.PrepareRequestContext(req, route.NumParams(r))
-		reqWithCtx := r.MatchAndUpdateContext(req)
-		reqWithCtx = middleware.ExecuteMiddleware(r.Middleware(), w, reqWithCtx)
-		if reqWithCtx == nil {
-			rctx.ReturnRequestContext(req)
+		if code, ok := t.disabled[leaf_id]; ok {
+			rctx.Release(ctx)
+			w.WriteHeader(code)
 			return
 		}
-		handler := rt.handlers[req.Method][leaf_id]
-		if handler != nil {
-			handler.ServeHTTP(w, reqWithCtx)
-		} else {
-			w.WriteHeader(http.StatusNotImplemented)
+		r := t.routes[leaf_id]
+		if t.foldRedirect && route.IsCaseInsensitive(r) {
+			if cased, changed := route.Casing(r, req.URL.Path); changed {
+				rctx.Release(ctx)
+				http.Redirect(w, req, redirectLocation(req, cased), redirectCode(req.Method))
+				return
+			}
+		}
+		if r.Method() != req.Method {
+			// Implicit HEAD; serve with the GET route, but drop the body.
+			// The status is only written if the route doesn't panic, so recovery can still set it.
+			hw := &headWriter{ResponseWriter: w}
+			t.serveRoute(hw, req, leaf_id, ctx)
+			hw.finish()
 			return
 		}
-
-		rctx.ReturnRequestContext(req)
+		t.serveRoute(w, req, leaf_id, ctx)
+		return
+	}
+	rctx.Release(ctx)
+	if allowed := t.rtree.Allowed(req); len(allowed) > 0 {
+		if t.autoOptions && req.Method == http.MethodOptions {
+			t.serveOptions(w, req, allowed)
+			return
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		t.notallowed.ServeHTTP(w, req)
 		return
 	}
-	rt.notfound.ServeHTTP(w, req)
+	t.notFound(req).ServeHTTP(w, req)
 	return
 }
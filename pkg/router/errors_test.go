@@ -0,0 +1,89 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/decentplatforms/matcha/pkg/httperr"
+	"github.com/decentplatforms/matcha/pkg/middleware"
+	"github.com/decentplatforms/matcha/pkg/rctx"
+	"github.com/decentplatforms/matcha/pkg/route"
+)
+
+// Return an error-returning handler that fails unless the id param is "1".
+func userHandler() httperr.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		switch id := rctx.GetParam(r.Context(), "id"); id {
+		case "1":
+			w.Write([]byte("user 1"))
+			return nil
+		case "db":
+			return errors.New("connection refused")
+		default:
+			return fmt.Errorf("get user %s: %w", id, httperr.New(http.StatusNotFound, "user not found"))
+		}
+	}
+}
+
+func TestHandleE(t *testing.T) {
+	rt := Declare(
+		Default(),
+		HandleE(http.MethodGet, "/users/[id]", userHandler()),
+	)
+	s := httptest.NewServer(rt)
+	runEvalRequest(t, s, "/users/1", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "user 1",
+	})
+	runEvalRequest(t, s, "/users/2", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusNotFound,
+		"body": "user not found",
+	})
+	runEvalRequest(t, s, "/users/db", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusInternalServerError,
+		"body": "Internal Server Error",
+	})
+}
+
+func TestErrorHandler(t *testing.T) {
+	jsonErrors := func(w http.ResponseWriter, req *http.Request, err error) {
+		var he *httperr.HTTPError
+		msg := "internal error"
+		if errors.As(err, &he) {
+			msg = he.Text()
+		}
+		w.WriteHeader(httperr.StatusCode(err))
+		fmt.Fprintf(w, `{"error":%q}`, msg)
+	}
+	rt := Declare(
+		Default(),
+		WithErrorHandler(jsonErrors),
+		HandleE(http.MethodGet, "/users/[id]", userHandler()),
+		HandleRoute(route.Declare(http.MethodGet, "/search", route.WithMiddleware(middleware.ExpectQueryParam("q"))), okHandler("search")),
+		Group("/v1", func(g Router) {
+			g.HandleE(http.MethodGet, "/users/[id]", userHandler())
+		}),
+	)
+	rt.Attach(middleware.ExpectHeader("X-Api-Key"))
+	s := httptest.NewServer(rt)
+	key := http.Header{"X-Api-Key": []string{"key"}}
+	runEvalRequest(t, s, "/users/2", reqGenHeaders(http.MethodGet, key), map[string]any{
+		"code": http.StatusNotFound,
+		"body": `{"error":"user not found"}`,
+	})
+	runEvalRequest(t, s, "/v1/users/db", reqGenHeaders(http.MethodGet, key), map[string]any{
+		"code": http.StatusInternalServerError,
+		"body": `{"error":"internal error"}`,
+	})
+	runEvalRequest(t, s, "/search", reqGenHeaders(http.MethodGet, key), map[string]any{
+		"code": http.StatusBadRequest,
+		"body": `{"error":"invalid value for query param q"}`,
+	})
+	runEvalRequest(t, s, "/users/1", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusBadRequest,
+		"body": `{"error":"invalid value for header X-Api-Key"}`,
+	})
+}
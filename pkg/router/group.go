@@ -0,0 +1,279 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/decentplatforms/matcha/pkg/cors"
+	"github.com/decentplatforms/matcha/pkg/httperr"
+	"github.com/decentplatforms/matcha/pkg/middleware"
+	"github.com/decentplatforms/matcha/pkg/path"
+	"github.com/decentplatforms/matcha/pkg/route"
+)
+
+// group is a Router that registers routes on its parent Router under a shared prefix and configuration.
+// Routes are joined onto the prefix when they are registered, so grouped routes are matched and served by the
+// parent like any other route.
+type group struct {
+	parent Router
+	prefix string
+	cfs    []route.ConfigFunc
+	mws    []middleware.Middleware
+	routes []route.Route
+	err    error
+}
+
+// mounter is implemented by Routers that can mount handlers with additional route configuration.
+type mounter interface {
+	mount(rpath string, h http.Handler, o *mountOptions, cfs ...route.ConfigFunc) ([]route.Route, error)
+}
+
+// newGroup validates a group prefix and configuration, and creates a group on a parent Router.
+func newGroup(parent Router, prefix string, cfs []route.ConfigFunc) (*group, error) {
+	if _, err := route.Join(prefix, route.Declare(http.MethodGet, "/"), cfs...); err != nil {
+		return nil, err
+	}
+	return &group{
+		parent: parent,
+		prefix: strings.TrimRight(path.Join("", prefix), "/"),
+		cfs:    cfs,
+	}, nil
+}
+
+// runGroup creates a group and calls fn on it, returning the first registration error in the group.
+func runGroup(parent Router, prefix string, fn func(g Router), cfs []route.ConfigFunc) error {
+	g, err := newGroup(parent, prefix, cfs)
+	if err != nil {
+		return err
+	}
+	fn(g)
+	return g.err
+}
+
+// fail records the first registration error in the group.
+func (g *group) fail(err error) error {
+	if g.err == nil {
+		g.err = err
+	}
+	return err
+}
+
+// configs gets the config functions applied to routes registered in the group.
+func (g *group) configs() []route.ConfigFunc {
+	cfs := append([]route.ConfigFunc{}, g.cfs...)
+	if len(g.mws) > 0 {
+		cfs = append(cfs, route.WithMiddleware(g.mws...))
+	}
+	return cfs
+}
+
+// register joins a route onto the group prefix and registers it on the parent.
+// Returns the route the parent stored, which the group keeps, since it may be a copy of the joined route.
+func (g *group) register(r route.Route, h http.Handler) (route.Route, error) {
+	joined, err := route.Join(g.prefix, r, g.configs()...)
+	if err != nil {
+		return nil, g.fail(err)
+	}
+	stored, err := registerRoute(g.parent, joined, h)
+	if err != nil {
+		return nil, g.fail(err)
+	}
+	g.routes = append(g.routes, stored)
+	return stored, nil
+}
+
+// handle adds a route to the group, and returns the route its Router stored for it.
+func (g *group) handle(r route.Route, h http.Handler) (route.Route, error) {
+	return g.register(r, h)
+}
+
+// Set CORS headers on routes registered on the group afterwards.
+// The options are set on each route with route.CORSHeaders, so they are also used to answer preflight requests.
+// Routes already registered aren't changed, since their Router may be serving them.
+func (g *group) defaultCORS(aco *cors.AccessControlOptions) {
+	g.cfs = append(g.cfs, route.CORSHeaders(aco))
+}
+
+// Attach middleware to routes registered on the group afterwards. Routes already registered aren't changed, since
+// their Router may be serving them.
+// The middleware runs after middleware from the group's config functions, and before the route's own middleware.
+//
+// See interface Router.
+func (g *group) Attach(mws ...middleware.Middleware) {
+	g.mws = append(g.mws, mws...)
+}
+
+// Add a route to the group.
+//
+// AddRoute is deprecated; use HandleRoute instead.
+//
+// See interface Router.
+func (g *group) AddRoute(r route.Route, h http.Handler) {
+	g.register(r, h)
+}
+
+// Add a route to the group.
+//
+// See interface Router.
+func (g *group) Handle(method, path string, h http.Handler) error {
+	r, err := route.New(method, path)
+	if err != nil {
+		return g.fail(err)
+	}
+	_, err = g.register(r, h)
+	return err
+}
+
+// Add a route to the group.
+//
+// See interface Router.
+func (g *group) HandleFunc(method, path string, h http.HandlerFunc) error {
+	if h == nil {
+		return g.Handle(method, path, nil)
+	}
+	return g.Handle(method, path, h)
+}
+
+// Add a route with an error-returning handler to the group.
+//
+// See interface Router.
+func (g *group) HandleE(method, path string, h httperr.HandlerFunc) error {
+	if h == nil {
+		return g.Handle(method, path, nil)
+	}
+	return g.Handle(method, path, h)
+}
+
+// Add a route to the group.
+// Errors registering the route, like errors joining it onto the group prefix, are returned by Group.
+//
+// See interface Router.
+func (g *group) HandleRoute(r route.Route, h http.Handler) {
+	g.register(r, h)
+}
+
+// Add a route to the group.
+// Errors registering the route, like errors joining it onto the group prefix, are returned by Group.
+//
+// See interface Router.
+func (g *group) HandleRouteFunc(r route.Route, h http.HandlerFunc) {
+	if h == nil {
+		g.register(r, nil)
+	} else {
+		g.register(r, h)
+	}
+}
+
+// Mount a handler at a path under the group prefix.
+// The routes used to mount the handler get the group's configuration.
+//
+// See interface Router.
+func (g *group) Mount(rpath string, h http.Handler, methods ...string) error {
+	return g.MountWith(rpath, h, MountMethods(methods...))
+}
+
+// Mount a handler at a path under the group prefix with options.
+// The routes used to mount the handler get the group's configuration.
+//
+// See interface Router.
+func (g *group) MountWith(rpath string, h http.Handler, opts ...MountOption) error {
+	_, err := g.mount(rpath, h, newMountOptions(opts))
+	return err
+}
+
+func (g *group) mount(rpath string, h http.Handler, o *mountOptions, cfs ...route.ConfigFunc) ([]route.Route, error) {
+	m, ok := g.parent.(mounter)
+	if !ok {
+		return nil, g.fail(errors.New("mounting in a group is not supported by its router"))
+	}
+	rs, err := m.mount(path.Join(g.prefix, rpath), h, o, append(g.configs(), cfs...)...)
+	if err != nil {
+		return nil, g.fail(err)
+	}
+	g.routes = append(g.routes, rs...)
+	return rs, nil
+}
+
+// Remove the routes with a method and an expression under the group prefix.
+//
+// See interface Router.
+func (g *group) Remove(method, expr string) error {
+	return g.parent.Remove(method, path.Join(g.prefix, expr))
+}
+
+// Disable the routes with a method and an expression under the group prefix.
+//
+// See interface Router.
+func (g *group) Disable(method, expr string, code int) error {
+	return g.parent.Disable(method, path.Join(g.prefix, expr), code)
+}
+
+// Enable the routes with a method and an expression under the group prefix.
+//
+// See interface Router.
+func (g *group) Enable(method, expr string) error {
+	return g.parent.Enable(method, path.Join(g.prefix, expr))
+}
+
+// Set the handler for requests under the group prefix that no route matches.
+// Errors setting the handler are returned by Group.
+//
+// See interface Router.
+func (g *group) AddNotFound(h http.Handler) {
+	g.AddNotFoundAt("", h)
+}
+
+// Groups share the MethodNotAllowed handler of their Router, so it can't be set on a group.
+// The error is returned by Group.
+//
+// See interface Router.
+func (g *group) AddMethodNotAllowed(h http.Handler) {
+	g.fail(errors.New("groups share the method not allowed handler of their router; set it on the router"))
+}
+
+// Create a group nested in the group.
+//
+// See interface Router.
+func (g *group) Group(prefix string, fn func(g Router), cfs ...route.ConfigFunc) error {
+	return g.fail(runGroup(g, prefix, fn, cfs))
+}
+
+// Get information about every route under the group prefix.
+//
+// See interface Router.
+func (g *group) Routes() []RouteInfo {
+	var infos []RouteInfo
+	g.Walk(func(info RouteInfo) error {
+		infos = append(infos, info)
+		return nil
+	})
+	return infos
+}
+
+// Call fn on information about every route under the group prefix.
+//
+// See interface Router.
+func (g *group) Walk(fn func(RouteInfo) error) error {
+	return g.parent.Walk(func(info RouteInfo) error {
+		if g.prefix != "" && info.Expr != g.prefix && !strings.HasPrefix(info.Expr, g.prefix+"/") {
+			return nil
+		}
+		return fn(info)
+	})
+}
+
+// Build the path of the route with the given name.
+// Route names are shared by every group in a Router.
+//
+// See interface Router.
+func (g *group) URL(name string, params map[string]string) (string, error) {
+	return g.parent.URL(name, params)
+}
+
+// Serve a request with the group's Router.
+//
+// See interface Router.
+func (g *group) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	g.parent.ServeHTTP(w, req)
+}
@@ -0,0 +1,217 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/decentplatforms/matcha/pkg/middleware"
+	"github.com/decentplatforms/matcha/pkg/route"
+	"github.com/decentplatforms/matcha/pkg/route/require"
+)
+
+// Reject requests without an Authorization header.
+func auth(w http.ResponseWriter, req *http.Request) *http.Request {
+	if req.Header.Get("Authorization") == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil
+	}
+	return req
+}
+
+func TestGroup(t *testing.T) {
+	rt := Declare(
+		Default(),
+		Group("/orgs/[org]", func(g Router) {
+			g.HandleFunc(http.MethodGet, "/", rpHandler("org"))
+			g.HandleFunc(http.MethodGet, "/repos/[repo]", rpHandler("repo"))
+			g.HandleRoute(route.Declare(http.MethodPost, "/repos", route.WithMiddleware(reject)), okHandler("post"))
+			g.Group("/members/", func(g Router) {
+				g.HandleFunc(http.MethodGet, "/[member]", rpHandler("org"))
+			})
+		}, route.WithMiddleware(auth)),
+		HandleFunc(http.MethodGet, "/orgs", okHandler("orgs")),
+	)
+	s := httptest.NewServer(rt)
+	authed := http.Header{"Authorization": {"token"}}
+
+	runEvalRequest(t, s, "/orgs/decent", reqGenHeaders(http.MethodGet, authed), map[string]any{
+		"code": http.StatusOK,
+		"body": "decent",
+	})
+	runEvalRequest(t, s, "/orgs/decent/repos/matcha", reqGenHeaders(http.MethodGet, authed), map[string]any{
+		"code": http.StatusOK,
+		"body": "matcha",
+	})
+	runEvalRequest(t, s, "/orgs/decent/members/me", reqGenHeaders(http.MethodGet, authed), map[string]any{
+		"code": http.StatusOK,
+		"body": "decent",
+	})
+	// Group middleware runs before route middleware.
+	runEvalRequest(t, s, "/orgs/decent/repos", reqGen(http.MethodPost), map[string]any{
+		"code": http.StatusUnauthorized,
+	})
+	runEvalRequest(t, s, "/orgs/decent/repos", reqGenHeaders(http.MethodPost, authed), map[string]any{
+		"code": http.StatusForbidden,
+	})
+	runEvalRequest(t, s, "/orgs/decent/repos/matcha", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusUnauthorized,
+	})
+	// Routes outside the group are unaffected.
+	runEvalRequest(t, s, "/orgs", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "orgs",
+	})
+
+	exprs := []string{}
+	for _, info := range rt.Routes() {
+		exprs = append(exprs, info.Expr)
+	}
+	expected := []string{"/orgs/[org]", "/orgs/[org]/repos/[repo]", "/orgs/[org]/repos", "/orgs/[org]/members/[member]", "/orgs"}
+	if len(exprs) != len(expected) {
+		t.Fatalf("expected routes %v, got %v", expected, exprs)
+	}
+	for i := range exprs {
+		if exprs[i] != expected[i] {
+			t.Errorf("expected route %s, got %s", expected[i], exprs[i])
+		}
+	}
+}
+
+func TestGroupRequire(t *testing.T) {
+	rt := Declare(
+		Default(),
+		Group("/api", func(g Router) {
+			g.HandleFunc(http.MethodGet, "/status", okHandler("api"))
+		}, route.Require(require.Hosts("api.decentplatforms.com"))),
+		HandleFunc(http.MethodGet, "/api/status", okHandler("web")),
+	)
+	req := httptest.NewRequest(http.MethodGet, "http://api.decentplatforms.com/api/status", nil)
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+	if body := w.Body.String(); body != "api" {
+		t.Errorf("expected body api, got %s", body)
+	}
+	req = httptest.NewRequest(http.MethodGet, "http://decentplatforms.com/api/status", nil)
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+	if body := w.Body.String(); body != "web" {
+		t.Errorf("expected body web, got %s", body)
+	}
+}
+
+func TestGroupAttachAndMount(t *testing.T) {
+	rt := Default()
+	err := rt.Group("/v1", func(g Router) {
+		g.HandleFunc(http.MethodGet, "/before", okHandler("before"))
+		g.Attach(auth)
+		g.HandleFunc(http.MethodGet, "/after", okHandler("after"))
+		if err := g.Mount("/files", http.StripPrefix("", okHandler("files")), http.MethodGet); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(rt)
+	// Routes registered before Attach aren't changed.
+	runEvalRequest(t, s, "/v1/before", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "before",
+	})
+	for _, p := range []string{"/v1/after", "/v1/files/a"} {
+		runEvalRequest(t, s, p, reqGen(http.MethodGet), map[string]any{
+			"code": http.StatusUnauthorized,
+		})
+	}
+	runEvalRequest(t, s, "/v1/files/a", reqGenHeaders(http.MethodGet, http.Header{"Authorization": {"token"}}), map[string]any{
+		"code": http.StatusOK,
+		"body": "files",
+	})
+}
+
+func TestGroupAttachConcurrent(t *testing.T) {
+	rt := Declare(Concurrent())
+	var wg sync.WaitGroup
+	err := rt.Group("/v1", func(g Router) {
+		g.HandleFunc(http.MethodGet, "/status", okHandler("status"))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/status", nil))
+			}
+		}()
+		// Attaching doesn't change routes the Router may be serving.
+		for i := 0; i < 50; i++ {
+			g.Attach(auth)
+		}
+		g.HandleFunc(http.MethodGet, "/private", okHandler("private"))
+	})
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, code := range map[string]int{"/v1/status": http.StatusOK, "/v1/private": http.StatusUnauthorized} {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, p, nil))
+		if w.Code != code {
+			t.Errorf("%s: expected %d, got %d", p, code, w.Code)
+		}
+	}
+}
+
+func TestGroupErrors(t *testing.T) {
+	rt := Default()
+	if err := rt.Group("/files/+", func(g Router) {}); err == nil {
+		t.Error("expected error for partial prefix")
+	}
+	err := rt.Group("/v1", func(g Router) {
+		g.HandleFunc(http.MethodGet, "/[bad]{(}", okHandler("bad"))
+		g.HandleFunc(http.MethodGet, "/ok", okHandler("ok"))
+	})
+	if err == nil {
+		t.Error("expected registration error from group")
+	}
+	if err := rt.Group("/v2", func(g Router) { g.AddMethodNotAllowed(okHandler("nope")) }); err == nil {
+		t.Error("expected error setting the method not allowed handler of a group")
+	}
+	if _, err := New(Default(), Group("/v1", func(g Router) {}, route.WithMiddleware(middleware.TrimPrefix("/v1")))); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGroupCaseInsensitive(t *testing.T) {
+	rt := Declare(Default(), CaseInsensitive(false))
+	var g Router
+	err := rt.Group("/api", func(gr Router) {
+		g = gr
+		gr.Attach(auth)
+		if err := DefaultCORSHeaders(aco)(gr); err != nil {
+			t.Fatal(err)
+		}
+		gr.HandleFunc(http.MethodGet, "/[x]", rpHandler("x"))
+		gr.HandleFunc(http.MethodGet, "/me", okHandler("me"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The group configures the routes the Router serves, which are case-insensitive copies of the joined routes.
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/API/Users", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected group middleware to reject the request, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/API/Users", nil)
+	req.Header.Set("Authorization", "token")
+	req.Header.Set("Origin", "test-origin")
+	rt.ServeHTTP(w, req)
+	if w.Body.String() != "Users" || w.Header().Get("Access-Control-Allow-Origin") == "" {
+		t.Errorf("expected Users with CORS headers, got %q %v", w.Body.String(), w.Header())
+	}
+	if problems, all := g.Validate(), rt.Validate(); len(problems) != 1 || len(all) != 1 || problems[0].Route != all[0].Route {
+		t.Errorf("expected the group to report the Router's problem, got %v and %v", problems, all)
+	}
+}
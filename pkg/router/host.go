@@ -0,0 +1,87 @@
+package router
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/decentplatforms/matcha/pkg/rctx"
+	"github.com/decentplatforms/matcha/pkg/regex"
+)
+
+// host is a Router served for requests to hosts matching a pattern.
+type host struct {
+	expr string
+	patt *regex.Pattern
+	rt   Router
+}
+
+// HostRouter dispatches requests to Routers by the request host, before any path is matched.
+type HostRouter struct {
+	hosts    []*host
+	notfound http.Handler
+}
+
+// Create a new HostRouter.
+func NewHostRouter() *HostRouter {
+	return &HostRouter{
+		hosts:    make([]*host, 0),
+		notfound: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) }),
+	}
+}
+
+// Serve requests to hosts matching expr with a Router.
+// Host expressions are patterns compiled with regex.CompileCapturePattern; names in square brackets capture host
+// labels as params, which handlers can get with rctx.GetParam. For example, [tenant].api.decentplatforms.com sets
+// param tenant to "acme" for requests to acme.api.decentplatforms.com.
+//
+// Hosts are matched case-insensitively and without their port, in the order they are added.
+// Returns an error if the expression is invalid.
+func (hr *HostRouter) Host(expr string, rt Router) error {
+	patt, err := regex.CompileCapturePattern(expr, true)
+	if err != nil {
+		return err
+	}
+	hr.hosts = append(hr.hosts, &host{expr, patt, rt})
+	return nil
+}
+
+// Set the handler for requests to hosts that don't match any host expression.
+func (hr *HostRouter) AddNotFound(h http.Handler) {
+	hr.notfound = h
+}
+
+// requestHost gets the host of a request without its port or trailing dot.
+func requestHost(req *http.Request) string {
+	h := req.Host
+	if hn, _, err := net.SplitHostPort(h); err == nil {
+		h = hn
+	}
+	return strings.TrimSuffix(h, ".")
+}
+
+// Implements http.Handler.
+//
+// Serve a request with the Router of the first host expression that matches the request host.
+// If none match, the request is passed to the NotFound handler.
+func (hr *HostRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	reqHost := requestHost(req)
+	for _, h := range hr.hosts {
+		values, ok := h.patt.Capture(reqHost)
+		if !ok {
+			continue
+		}
+		if len(values) == 0 {
+			h.rt.ServeHTTP(w, req)
+			return
+		}
+		req = rctx.PrepareRequestContext(req, len(values))
+		defer rctx.ReturnRequestContext(req)
+		for i, name := range h.patt.Names() {
+			rctx.SetParam(req.Context(), name, values[i])
+		}
+		h.rt.ServeHTTP(w, req)
+		return
+	}
+	hr.notfound.ServeHTTP(w, req)
+}
@@ -0,0 +1,52 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostRouter(t *testing.T) {
+	hr := NewHostRouter()
+	api := Declare(
+		Default(),
+		HandleFunc(http.MethodGet, "/tenant", rpHandler("tenant")),
+		HandleFunc(http.MethodGet, "/users/[id]", rpHandler("id")),
+	)
+	www := Declare(
+		Default(),
+		HandleFunc(http.MethodGet, "/", okHandler("www")),
+	)
+	if err := hr.Host("[tenant].api.decentplatforms.com", api); err != nil {
+		t.Fatal(err)
+	}
+	if err := hr.Host("{(www.)?}decentplatforms.com", www); err != nil {
+		t.Fatal(err)
+	}
+	if err := hr.Host("[bad.com", www); err == nil {
+		t.Error("expected error for invalid host expression")
+	}
+	hr.AddNotFound(nfHandler())
+
+	tests := []struct {
+		url  string
+		code int
+		body string
+	}{
+		{"http://acme.api.decentplatforms.com/tenant", http.StatusOK, "acme"},
+		{"http://ACME.API.decentplatforms.com:8080/tenant", http.StatusOK, "ACME"},
+		{"http://acme.api.decentplatforms.com/users/12", http.StatusOK, "12"},
+		{"http://acme.api.decentplatforms.com/nope", http.StatusNotFound, ""},
+		{"http://decentplatforms.com/", http.StatusOK, "www"},
+		{"http://www.decentplatforms.com./", http.StatusOK, "www"},
+		{"http://blog.decentplatforms.com/", http.StatusNotFound, "not found"},
+		{"http://a.b.api.decentplatforms.com/tenant", http.StatusNotFound, "not found"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		hr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.url, nil))
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("%s: expected %d %q, got %d %q", test.url, test.code, test.body, w.Code, w.Body.String())
+		}
+	}
+}
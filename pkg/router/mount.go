@@ -0,0 +1,143 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/decentplatforms/matcha/pkg/middleware"
+	"github.com/decentplatforms/matcha/pkg/path"
+	"github.com/decentplatforms/matcha/pkg/rctx"
+	"github.com/decentplatforms/matcha/pkg/route"
+)
+
+// Header set by the ForwardPrefix mount option.
+const ForwardedPrefix = "X-Forwarded-Prefix"
+
+// MountOptions configure how a handler is mounted with MountWith.
+type MountOption func(o *mountOptions)
+
+type mountOptions struct {
+	methods  []string
+	preserve bool
+	forward  bool
+	trust    bool
+}
+
+// newMountOptions applies a set of MountOptions to the defaults.
+func newMountOptions(opts []MountOption) *mountOptions {
+	o := &mountOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if len(o.methods) == 0 {
+		o.methods = []string{
+			http.MethodPut, http.MethodGet, http.MethodPatch, http.MethodDelete, http.MethodPost,
+			http.MethodOptions, http.MethodHead, http.MethodTrace, http.MethodConnect,
+		}
+	}
+	return o
+}
+
+// Only mount the handler for a set of methods.
+// If no methods are given, the handler is mounted for all methods.
+func MountMethods(methods ...string) MountOption {
+	return func(o *mountOptions) {
+		o.methods = append(o.methods, methods...)
+	}
+}
+
+// Pass requests to the mounted handler without trimming the mount prefix from their path.
+// The prefix is still recorded in the request context; see rctx.GetPrefix.
+func PreservePath() MountOption {
+	return func(o *mountOptions) {
+		o.preserve = true
+	}
+}
+
+// Set the X-Forwarded-Prefix header of requests passed to the mounted handler to the prefix stripped from their
+// path, including the prefixes of outer mounts.
+// Any value the request already has is replaced, since clients can set it to anything; use TrustForwardedPrefix
+// to keep a value set by a proxy in front of the router.
+func ForwardPrefix() MountOption {
+	return func(o *mountOptions) {
+		o.forward = true
+	}
+}
+
+// Like ForwardPrefix, but append the mount prefix to the X-Forwarded-Prefix header the request already has,
+// instead of replacing it. Only use this if a trusted proxy always sets the header.
+// Mounts inside this one that replace the header drop the value from the proxy, so they should trust it too.
+func TrustForwardedPrefix() MountOption {
+	return func(o *mountOptions) {
+		o.forward = true
+		o.trust = true
+	}
+}
+
+// mountMiddleware creates the middleware that passes requests matched by a mount route with n prefix
+// segments to the mounted handler.
+func mountMiddleware(n int, o *mountOptions) middleware.Middleware {
+	trim := middleware.TrimSegments(n)
+	return func(w http.ResponseWriter, req *http.Request) *http.Request {
+		prefix, _, _ := path.SplitSegments(req.URL.Path, n)
+		if o.preserve {
+			rctx.SetPrefix(req.Context(), prefix)
+		} else {
+			req = trim(w, req)
+		}
+		if o.forward {
+			forwarded := rctx.GetPrefix(req.Context())
+			if o.trust {
+				forwarded = strings.TrimRight(req.Header.Get(ForwardedPrefix), "/") + prefix
+			}
+			// The headers are shared with the request the mount was passed, so they're copied before they're changed.
+			r2 := new(http.Request)
+			*r2 = *req
+			r2.Header = req.Header.Clone()
+			r2.Header.Set(ForwardedPrefix, forwarded)
+			req = r2
+		}
+		return req
+	}
+}
+
+// mount mounts a handler at path, applying cfs to each route used to mount it.
+// Returns the routes used to mount the handler.
+func (rt *defaultRouter) mount(rpath string, h http.Handler, o *mountOptions, cfs ...route.ConfigFunc) ([]route.Route, error) {
+	rpath = path.MakePartial(rpath, "")
+	m := &mount{h: h, methods: o.methods}
+	rs := make([]route.Route, 0, len(o.methods))
+	for _, method := range o.methods {
+		r, err := route.New(method, rpath, cfs...)
+		if err != nil {
+			return nil, err
+		}
+		// Trim by segment, so prefixes with params are trimmed whatever they matched.
+		r.Attach(mountMiddleware(r.Length(), o))
+		rs = append(rs, r)
+	}
+	var err error
+	rt.edit(func(t *table) {
+		ids := make([]int, 0, len(rs))
+		for i, r := range rs {
+			m.prefix = strings.TrimSuffix(route.ExprOf(r), "/+")
+			var id int
+			if id, err = t.add(r, h); err != nil {
+				// Mount the handler for every method or none.
+				for _, id := range ids {
+					t.unregister(id)
+				}
+				return
+			}
+			// Return the routes the table stored, which are copies of rs on case-insensitive Routers.
+			rs[i] = t.routes[id]
+			m.r = rs[i]
+			t.mounts[id] = m
+			ids = append(ids, id)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
@@ -0,0 +1,94 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/decentplatforms/matcha/pkg/rctx"
+)
+
+// Write the path, raw path, stripped prefix, and forwarded prefix of a request.
+func mountHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "%s|%s|%s|%s", r.URL.Path, r.URL.RawPath, rctx.GetPrefix(r.Context()), r.Header.Get(ForwardedPrefix))
+}
+
+func TestMountWith(t *testing.T) {
+	inner := Declare(
+		Default(),
+		HandleFunc(http.MethodGet, "/[file]+", mountHandler),
+	)
+	outer := Default()
+	if err := outer.MountWith("/orgs/[org]", inner, MountMethods(http.MethodGet), ForwardPrefix()); err != nil {
+		t.Fatal(err)
+	}
+	rt := Default()
+	if err := rt.MountWith("/api", outer, ForwardPrefix()); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.MountWith("/ui", http.HandlerFunc(mountHandler), PreservePath(), MountMethods(http.MethodGet)); err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(rt)
+
+	runEvalRequest(t, s, "/api/orgs/decent/docs/read%20me", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "/docs/read me||/api/orgs/decent|/api/orgs/decent",
+	})
+	runEvalRequest(t, s, "/api/orgs/decent/a%2Fb", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "/a/b|/a%2Fb|/api/orgs/decent|/api/orgs/decent",
+	})
+	// Prefixes sent by the client are replaced.
+	runEvalRequest(t, s, "/api/orgs/decent/docs", reqGenHeaders(http.MethodGet, http.Header{ForwardedPrefix: {"/proxy/"}}), map[string]any{
+		"code": http.StatusOK,
+		"body": "/docs||/api/orgs/decent|/api/orgs/decent",
+	})
+	runEvalRequest(t, s, "/api/orgs/decent/docs", reqGen(http.MethodPost), map[string]any{
+		"code":   http.StatusMethodNotAllowed,
+		"header": http.Header{"Allow": {"GET"}},
+	})
+	runEvalRequest(t, s, "/ui/assets/app.js", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "/ui/assets/app.js||/ui|",
+	})
+	// Mount prefixes end on segment boundaries.
+	runEvalRequest(t, s, "/uix/assets/app.js", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusNotFound,
+	})
+}
+
+func TestTrustForwardedPrefix(t *testing.T) {
+	inner := Declare(
+		Default(),
+		HandleFunc(http.MethodGet, "/[file]+", mountHandler),
+	)
+	outer := Default()
+	if err := outer.MountWith("/orgs/[org]", inner, TrustForwardedPrefix()); err != nil {
+		t.Fatal(err)
+	}
+	rt := Default()
+	if err := rt.MountWith("/api", outer, TrustForwardedPrefix()); err != nil {
+		t.Fatal(err)
+	}
+	var seen http.Header
+	rt.Attach(func(w http.ResponseWriter, r *http.Request) *http.Request {
+		seen = r.Header
+		return r
+	})
+	s := httptest.NewServer(rt)
+	defer s.Close()
+	runEvalRequest(t, s, "/api/orgs/decent/docs", reqGenHeaders(http.MethodGet, http.Header{ForwardedPrefix: {"/proxy/"}}), map[string]any{
+		"code": http.StatusOK,
+		"body": "/docs||/api/orgs/decent|/proxy/api/orgs/decent",
+	})
+	runEvalRequest(t, s, "/api/orgs/decent/docs", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "/docs||/api/orgs/decent|/api/orgs/decent",
+	})
+	// The mounts set the header on copies of the request.
+	if v := seen.Get(ForwardedPrefix); v != "" {
+		t.Errorf("expected the router's request to keep its headers, got %s", v)
+	}
+}
@@ -0,0 +1,98 @@
+package router
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/decentplatforms/matcha/pkg/path"
+	"github.com/decentplatforms/matcha/pkg/route"
+	"github.com/decentplatforms/matcha/pkg/tree"
+)
+
+// scopeMethod is the method of the routes used to scope NotFound handlers, so they match requests with any method.
+const scopeMethod = ""
+
+// notFoundKey is the context key for the NotFound handler a Router passes to the handlers mounted on it.
+type notFoundKey struct{}
+
+// defaultNotFound responds to unmatched requests when no NotFound handler is set.
+var defaultNotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) })
+
+// scope is a NotFound handler for requests under a path prefix.
+type scope struct {
+	r route.Route
+	h http.Handler
+}
+
+// setScope sets the NotFound handler for requests matched by the partial route r, replacing the handler of a
+// scope with the same prefix. If h is nil, the scope is removed.
+func (t *table) setScope(r route.Route, h http.Handler) {
+	if t.scopes == nil {
+		// Scopes are matched by specificity, so handlers for longer prefixes take priority.
+		t.scopes = tree.New()
+		t.scopes.SetPriority(true)
+		t.scoped = make(map[int]*scope)
+	}
+	for id, s := range t.scoped {
+		if s.r.Hash() == r.Hash() {
+			if h == nil {
+				t.scopes.Remove(id)
+				delete(t.scoped, id)
+			} else {
+				t.scoped[id] = &scope{r, h}
+			}
+			return
+		}
+	}
+	if h != nil {
+		t.scoped[t.scopes.Add(r)] = &scope{r, h}
+	}
+}
+
+// notFound gets the handler for a request that no route matches.
+// Handlers scoped to the longest matching prefix come first, then the Router's NotFound handler, then the
+// handler passed down by a Router this one is mounted on.
+func (t *table) notFound(req *http.Request) http.Handler {
+	if t.scopes != nil {
+		if id := t.scopes.MatchMethod(req, scopeMethod); id != tree.NO_LEAF_ID {
+			return t.scoped[id].h
+		}
+	}
+	if t.notfound != nil {
+		return t.notfound
+	}
+	if h, ok := req.Context().Value(notFoundKey{}).(http.Handler); ok {
+		return h
+	}
+	return defaultNotFound
+}
+
+// inheritNotFound passes the handler for unmatched requests under a mount to the mounted handler, so Routers
+// mounted without their own NotFound handlers use it.
+func (t *table) inheritNotFound(req *http.Request) *http.Request {
+	if t.scopes == nil && t.notfound == nil {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), notFoundKey{}, t.notFound(req)))
+}
+
+// Set the handler for requests under a path prefix that no route matches.
+//
+// See interface Router.
+func (rt *defaultRouter) AddNotFoundAt(prefix string, h http.Handler) error {
+	r, err := route.New(scopeMethod, path.MakePartial(path.Join("", prefix), ""))
+	if err != nil {
+		return err
+	}
+	rt.edit(func(t *table) {
+		t.setScope(r, h)
+	})
+	return nil
+}
+
+// Set the handler for requests under a path prefix in the group that no route matches.
+//
+// See interface Router.
+func (g *group) AddNotFoundAt(prefix string, h http.Handler) error {
+	return g.fail(g.parent.AddNotFoundAt(path.Join(g.prefix, prefix), h))
+}
@@ -0,0 +1,115 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNotFoundAt(t *testing.T) {
+	jsonNotFound := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"not found"}`))
+	}
+	rt := Declare(
+		Default(),
+		WithNotFound(nfHandler()),
+		HandleFunc(http.MethodGet, "/api/users", okHandler("users")),
+		WithNotFoundAt("/api", http.HandlerFunc(jsonNotFound)),
+		WithNotFoundAt("/api/v2", okHandler("v2")),
+		WithNotFoundAt("/app", okHandler("index")),
+		WithNotFoundAt("/tenants/[tenant]/files", okHandler("files")),
+		Group("/admin", func(g Router) {
+			g.HandleFunc(http.MethodGet, "/", okHandler("admin"))
+			g.AddNotFound(okHandler("admin not found"))
+		}),
+	)
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		body   string
+	}{
+		{http.MethodGet, "/api/users", http.StatusOK, "users"},
+		{http.MethodGet, "/api/posts", http.StatusNotFound, `{"error":"not found"}`},
+		{http.MethodPost, "/api", http.StatusNotFound, `{"error":"not found"}`},
+		{http.MethodGet, "/api/v2/posts", http.StatusOK, "v2"},
+		{http.MethodGet, "/app/settings/profile", http.StatusOK, "index"},
+		{http.MethodGet, "/tenants/acme/files/a.txt", http.StatusOK, "files"},
+		{http.MethodGet, "/tenants/acme/users", http.StatusNotFound, "not found"},
+		{http.MethodGet, "/admin/users", http.StatusOK, "admin not found"},
+		{http.MethodGet, "/apiary", http.StatusNotFound, "not found"},
+		{http.MethodGet, "/other", http.StatusNotFound, "not found"},
+		// Method not allowed is unaffected by scoped handlers.
+		{http.MethodPost, "/api/users", http.StatusMethodNotAllowed, ""},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("%s %s: expected %d %q, got %d %q", test.method, test.path, test.code, test.body, w.Code, w.Body.String())
+		}
+	}
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/posts", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON content type, got %q", ct)
+	}
+	// Removing a scope falls back to the next longest prefix.
+	if err := rt.AddNotFoundAt("/api/v2", nil); err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v2/posts", nil))
+	if w.Body.String() != `{"error":"not found"}` {
+		t.Errorf("expected /api handler after removing /api/v2, got %q", w.Body.String())
+	}
+	if err := rt.AddNotFoundAt("/[bad", okHandler("")); err == nil {
+		t.Error("expected invalid prefix to fail")
+	}
+}
+
+func TestNotFoundAtDefault(t *testing.T) {
+	rt := Declare(Default(), WithNotFoundAt("/api", okHandler("api")))
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/other", nil))
+	if w.Code != http.StatusNotFound || w.Body.Len() != 0 {
+		t.Errorf("expected empty 404 outside scope, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestNotFoundAtMounted(t *testing.T) {
+	inherits := Declare(Default(), HandleFunc(http.MethodGet, "/users", okHandler("users")))
+	own := Declare(
+		Default(),
+		WithNotFound(okHandler("own")),
+		HandleFunc(http.MethodGet, "/users", okHandler("users")),
+	)
+	rt := Declare(Default(), WithNotFoundAt("/api", okHandler("api")), WithNotFound(nfHandler()))
+	if err := rt.Mount("/api/inherits", inherits); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Mount("/api/own", own); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Mount("/plain", Declare(Default())); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/api/inherits/users", "users"},
+		{"/api/inherits/posts", "api"},
+		{"/api/own/posts", "own"},
+		{"/plain/posts", "not found"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if w.Body.String() != test.body {
+			t.Errorf("%s: expected %q, got %q", test.path, test.body, w.Body.String())
+		}
+	}
+}
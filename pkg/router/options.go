@@ -0,0 +1,66 @@
+package router
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/decentplatforms/matcha/pkg/cors"
+	"github.com/decentplatforms/matcha/pkg/route"
+	"github.com/decentplatforms/matcha/pkg/tree"
+)
+
+// corsDefaulter is implemented by Routers that keep their default CORS options.
+type corsDefaulter interface {
+	defaultCORS(aco *cors.AccessControlOptions)
+}
+
+// Set CORS headers on every request, and keep the options to answer preflight requests.
+func (rt *defaultRouter) defaultCORS(aco *cors.AccessControlOptions) {
+	rt.edit(func(t *table) {
+		t.mws = append(t.mws, cors.CORSMiddleware(aco))
+		t.cors = aco
+	})
+}
+
+// isPreflight checks if a request is a CORS preflight request.
+func isPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions && req.Header.Get(cors.Origin) != "" && req.Header.Get(cors.RequestMethod) != ""
+}
+
+// allowsMethod checks if a set of access control options permits a method.
+func allowsMethod(aco *cors.AccessControlOptions, method string) bool {
+	for _, allowed := range aco.AllowMethods {
+		if allowed == "*" || allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+// serveOptions answers an OPTIONS request for a path that has routes for the allowed methods.
+func (t *table) serveOptions(w http.ResponseWriter, req *http.Request, allowed []string) {
+	allowed = append(allowed, http.MethodOptions)
+	sort.Strings(allowed)
+	h := w.Header()
+	h.Set("Allow", strings.Join(allowed, ", "))
+	if isPreflight(req) {
+		aco := t.cors
+		method := req.Header.Get(cors.RequestMethod)
+		if leaf_id := t.rtree.MatchMethod(req, method); leaf_id != tree.NO_LEAF_ID {
+			if raco := route.GetCORSHeaders(t.routes[leaf_id]); raco != nil {
+				aco = raco
+			}
+		}
+		if aco != nil {
+			cors.SetCORSResponseHeaders(w, req, aco)
+			h.Del(cors.AllowMethods)
+			for _, method := range allowed {
+				if allowsMethod(aco, method) {
+					h.Add(cors.AllowMethods, method)
+				}
+			}
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,96 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/decentplatforms/matcha/pkg/cors"
+	"github.com/decentplatforms/matcha/pkg/route"
+)
+
+func TestAutoOptions(t *testing.T) {
+	routeAco := &cors.AccessControlOptions{
+		AllowOrigin:  []string{"route-origin"},
+		AllowMethods: []string{http.MethodGet, http.MethodPut},
+		AllowHeaders: []string{"*"},
+		MaxAge:       10,
+	}
+	rt := Declare(
+		Default(),
+		AutoOptions(),
+		DefaultCORSHeaders(aco),
+		HandleFunc(http.MethodGet, "/items/[id]", okHandler("get")),
+		HandleFunc(http.MethodDelete, "/items/[id]", okHandler("delete")),
+		HandleRoute(route.Declare(http.MethodGet, "/restricted", route.CORSHeaders(routeAco)), okHandler("get")),
+		HandleRoute(route.Declare(http.MethodPut, "/restricted"), okHandler("put")),
+		HandleRoute(route.Declare(http.MethodPost, "/restricted"), okHandler("post")),
+		PreflightCORS("/explicit", aco),
+		HandleFunc(http.MethodGet, "/explicit", okHandler("get")),
+	)
+	s := httptest.NewServer(rt)
+
+	// Plain OPTIONS requests only get Allow.
+	runEvalRequest(t, s, "/items/1", reqGen(http.MethodOptions), map[string]any{
+		"code":   http.StatusNoContent,
+		"header": http.Header{"Allow": {"DELETE, GET, OPTIONS"}},
+	})
+	// Preflight with router defaults.
+	runEvalRequest(t, s, "/items/1", reqGenHeaders(http.MethodOptions, http.Header{
+		"Origin":                        {"test-origin"},
+		"Access-Control-Request-Method": {http.MethodDelete},
+	}), map[string]any{
+		"code": http.StatusNoContent,
+		"header": http.Header{
+			"Allow":                        {"DELETE, GET, OPTIONS"},
+			"Access-Control-Allow-Origin":  {"test-origin"},
+			"Access-Control-Allow-Methods": {"DELETE", "GET", "OPTIONS"},
+		},
+	})
+	// Preflight with route options.
+	runEvalRequest(t, s, "/restricted", reqGenHeaders(http.MethodOptions, http.Header{
+		"Origin":                        {"route-origin"},
+		"Access-Control-Request-Method": {http.MethodGet},
+	}), map[string]any{
+		"code": http.StatusNoContent,
+		"header": http.Header{
+			"Allow":                        {"GET, OPTIONS, POST, PUT"},
+			"Access-Control-Allow-Origin":  {"route-origin"},
+			"Access-Control-Allow-Methods": {"GET", "PUT"},
+			"Access-Control-Max-Age":       {"10"},
+		},
+	})
+	// Unmatched paths are still not found.
+	runEvalRequest(t, s, "/missing", reqGen(http.MethodOptions), map[string]any{
+		"code": http.StatusNotFound,
+	})
+	// Explicit routes take priority.
+	runEvalRequest(t, s, "/explicit", reqGenHeaders(http.MethodOptions, http.Header{
+		"Origin":                        {"test-origin"},
+		"Access-Control-Request-Method": {http.MethodPost},
+	}), map[string]any{
+		"code": http.StatusNoContent,
+		"header": http.Header{
+			"Allow":                        {},
+			"Access-Control-Allow-Methods": {http.MethodPost},
+		},
+	})
+
+	// Without AutoOptions, OPTIONS requests are not allowed.
+	w := httptest.NewRecorder()
+	Declare(Default(), HandleFunc(http.MethodGet, "/", okHandler("get"))).
+		ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+type otherRouter struct {
+	Router
+}
+
+func TestAutoOptionsUnsupported(t *testing.T) {
+	if _, err := New(&otherRouter{Default()}, AutoOptions()); err == nil {
+		t.Error("expected AutoOptions to fail on a non-default router")
+	}
+}
@@ -0,0 +1,90 @@
+package router
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/decentplatforms/matcha/pkg/path"
+)
+
+// PathMode controls how a Router treats request paths that aren't in canonical form.
+// See WithPathMode.
+type PathMode int
+
+const (
+	// Consecutive slashes act as a single slash when matching, and paths aren't cleaned; trailing slashes
+	// only match routes that end with a slash, or partial routes. This is the default.
+	PathLenient PathMode = iota
+	// Like PathLenient, except requests with consecutive slashes in their path don't match any route.
+	PathStrict
+	// Requests are redirected to the canonical form of their path, if a route matches it and the path
+	// isn't canonical already or doesn't match a route as it is. The canonical path is cleaned, with dot segments
+	// resolved, and has a trailing slash only if that's what the matching route expects.
+	PathRedirect
+)
+
+// toggleSlash adds a trailing slash to a path, or removes it if it has one.
+func toggleSlash(p string) string {
+	if p == "/" {
+		return p
+	} else if strings.HasSuffix(p, "/") {
+		return p[:len(p)-1]
+	}
+	return p + "/"
+}
+
+// matchesPath checks if a route for any method matches a request with its path replaced.
+func (t *table) matchesPath(req *http.Request, p string) bool {
+	u := *req.URL
+	u.Path, u.RawPath = p, ""
+	r := new(http.Request)
+	*r = *req
+	r.URL = &u
+	return len(t.rtree.Allowed(r)) > 0
+}
+
+// redirectLocation gets the Location to redirect a request to for a canonical path.
+// If the request path was trimmed by a mount or middleware, the trimmed prefix of the original request path is
+// added back. The whole path is cleaned, so a prefix with consecutive slashes, like "//evil.com", can't make the
+// Location a network-path reference to another host.
+func redirectLocation(req *http.Request, canonical string) string {
+	full := req.URL.Path
+	if u, err := url.ParseRequestURI(req.RequestURI); err == nil {
+		full = u.Path
+	}
+	var prefix string
+	if strings.HasSuffix(full, req.URL.Path) {
+		prefix = full[:len(full)-len(req.URL.Path)]
+	}
+	loc := url.URL{Path: path.Clean(prefix + canonical), RawQuery: req.URL.RawQuery}
+	return loc.String()
+}
+
+// redirectCode gets the status code to redirect requests with method to their canonical path.
+// GET and HEAD requests are redirected with 301 (Moved Permanently); other methods are redirected with 308
+// (Permanent Redirect), so the method and body are kept.
+func redirectCode(method string) int {
+	if method == http.MethodGet || method == http.MethodHead {
+		return http.StatusMovedPermanently
+	}
+	return http.StatusPermanentRedirect
+}
+
+// redirect redirects a request to the canonical form of its path, if a route matches it.
+// Returns false if no route matches the canonical path.
+func (t *table) redirect(w http.ResponseWriter, req *http.Request) bool {
+	p := req.URL.Path
+	if p == "" {
+		p = "/"
+	}
+	clean := path.Clean(p)
+	for _, canonical := range []string{clean, toggleSlash(clean)} {
+		if canonical == p || !t.matchesPath(req, canonical) {
+			continue
+		}
+		http.Redirect(w, req, redirectLocation(req, canonical), redirectCode(req.Method))
+		return true
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/decentplatforms/matcha/pkg/rctx"
+	"github.com/decentplatforms/matcha/pkg/route"
+)
+
+func TestPathModes(t *testing.T) {
+	routes := []ConfigFunc{
+		HandleFunc(http.MethodGet, "/users", okHandler("users")),
+		HandleFunc(http.MethodPost, "/users", okHandler("create")),
+		HandleFunc(http.MethodGet, "/dir/", okHandler("dir")),
+		HandleFunc(http.MethodGet, "/files/+", okHandler("files")),
+	}
+	lenient := Declare(Default(), routes...)
+	strict := Declare(Default(), append([]ConfigFunc{WithPathMode(PathStrict)}, routes...)...)
+	redirect := Declare(Default(), append([]ConfigFunc{WithPathMode(PathRedirect)}, routes...)...)
+	tests := []struct {
+		method   string
+		path     string
+		lenient  int
+		strict   int
+		redirect int
+		location string
+	}{
+		{http.MethodGet, "/users", http.StatusOK, http.StatusOK, http.StatusOK, ""},
+		{http.MethodGet, "/users/", http.StatusNotFound, http.StatusNotFound, http.StatusMovedPermanently, "/users"},
+		{http.MethodGet, "//users", http.StatusOK, http.StatusNotFound, http.StatusMovedPermanently, "/users"},
+		{http.MethodGet, "/users//?q=1", http.StatusNotFound, http.StatusNotFound, http.StatusMovedPermanently, "/users?q=1"},
+		{http.MethodGet, "/a/../users", http.StatusNotFound, http.StatusNotFound, http.StatusMovedPermanently, "/users"},
+		{http.MethodPost, "/users/", http.StatusNotFound, http.StatusNotFound, http.StatusPermanentRedirect, "/users"},
+		{http.MethodGet, "/dir", http.StatusNotFound, http.StatusNotFound, http.StatusMovedPermanently, "/dir/"},
+		{http.MethodGet, "/dir//", http.StatusOK, http.StatusNotFound, http.StatusMovedPermanently, "/dir/"},
+		{http.MethodGet, "/files/", http.StatusOK, http.StatusOK, http.StatusOK, ""},
+		{http.MethodGet, "/files//a", http.StatusOK, http.StatusNotFound, http.StatusMovedPermanently, "/files/a"},
+		{http.MethodGet, "/other/", http.StatusNotFound, http.StatusNotFound, http.StatusNotFound, ""},
+		// Redirects go to routes for any method.
+		{http.MethodPut, "/users/", http.StatusNotFound, http.StatusNotFound, http.StatusPermanentRedirect, "/users"},
+	}
+	for _, test := range tests {
+		for _, mode := range []struct {
+			name string
+			rt   Router
+			code int
+		}{{"lenient", lenient, test.lenient}, {"strict", strict, test.strict}, {"redirect", redirect, test.redirect}} {
+			w := httptest.NewRecorder()
+			mode.rt.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+			if w.Code != mode.code {
+				t.Errorf("%s %s in %s mode: expected code %d, got %d", test.method, test.path, mode.name, mode.code, w.Code)
+			}
+			if mode.name == "redirect" && w.Header().Get("Location") != test.location {
+				t.Errorf("%s %s: expected Location %q, got %q", test.method, test.path, test.location, w.Header().Get("Location"))
+			}
+		}
+	}
+	if err := WithPathMode(PathMode(10))(Default()); err == nil {
+		t.Error("expected invalid path mode to fail")
+	}
+}
+
+func TestPathRedirectMounted(t *testing.T) {
+	sub := Declare(
+		Default(),
+		WithPathMode(PathRedirect),
+		HandleFunc(http.MethodGet, "/users", okHandler("users")),
+	)
+	rt := Declare(Default())
+	if err := rt.Mount("/api", sub); err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/users/", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/api/users" {
+		t.Errorf("expected redirect to /api/users, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestPathRedirectOpenRedirect(t *testing.T) {
+	sub := Declare(
+		Default(),
+		WithPathMode(PathRedirect),
+		HandleFunc(http.MethodGet, "/foo", okHandler("foo")),
+		HandleFunc(http.MethodGet, "/users", okHandler("users")),
+	)
+	rt := Declare(Default())
+	if err := rt.Mount("/[tenant]", sub); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Mount("/api", sub); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path     string
+		location string
+	}{
+		// The mount prefix is taken from the request, so it can't add a host to the Location.
+		{"//evil.com/foo/", "/evil.com/foo"},
+		{"//api/users/", "/api/users"},
+		{"/acme/foo/", "/acme/foo"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		req.RequestURI = test.path
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, req)
+		if loc := w.Header().Get("Location"); w.Code != http.StatusMovedPermanently || loc != test.location {
+			t.Errorf("%s: expected redirect to %s, got %d %q", test.path, test.location, w.Code, loc)
+		}
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		code     int
+		body     string
+		location string
+	}{
+		{http.MethodGet, "/Users/AbC", http.StatusOK, "AbC", ""},
+		{http.MethodGet, "/users/AbC", http.StatusMovedPermanently, "", "/Users/AbC"},
+		{http.MethodGet, "/USERS/AbC?x=1", http.StatusMovedPermanently, "", "/Users/AbC?x=1"},
+		{http.MethodPost, "/users", http.StatusPermanentRedirect, "", "/Users"},
+		{http.MethodGet, "/api/Status", http.StatusMovedPermanently, "", "/Api/Status"},
+		// Routes registered before the option are case-sensitive.
+		{http.MethodGet, "/early", http.StatusNotFound, "", ""},
+	}
+	newRouter := func(redirect bool) Router {
+		rt := Declare(
+			Default(),
+			HandleFunc(http.MethodGet, "/Early", okHandler("early")),
+			CaseInsensitive(redirect),
+			HandleFunc(http.MethodGet, "/Users/[id]", rpHandler("id")),
+			HandleFunc(http.MethodPost, "/Users", okHandler("create")),
+		)
+		if err := rt.Mount("/Api", okHandler("api")); err != nil {
+			t.Fatal(err)
+		}
+		return rt
+	}
+	redirect := newRouter(true)
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		redirect.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		if w.Code != test.code || w.Header().Get("Location") != test.location {
+			t.Errorf("%s %s: expected %d %q, got %d %q", test.method, test.path, test.code, test.location, w.Code, w.Header().Get("Location"))
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s %s: expected body %q, got %q", test.method, test.path, test.body, w.Body.String())
+		}
+	}
+	// Without redirects, any casing is served, and params keep the request casing.
+	s := httptest.NewServer(newRouter(false))
+	runEvalRequest(t, s, "/USERS/AbC", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "AbC",
+	})
+	runEvalRequest(t, s, "/api/Status", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "api",
+	})
+}
+
+func TestCaseInsensitiveCopy(t *testing.T) {
+	r := route.Declare(http.MethodGet, "/Users")
+	rt := Declare(Default(), CaseInsensitive(false), HandleRoute(r, okHandler("users")))
+	// The router folds a copy, so the route can still be used case-sensitively.
+	if route.IsCaseInsensitive(r) {
+		t.Error("expected registered route not to be changed")
+	}
+	if req := httptest.NewRequest(http.MethodGet, "/users", nil); r.MatchAndUpdateContext(rctx.PrepareRequestContext(req, 0)) != nil {
+		t.Error("expected registered route to stay case-sensitive")
+	}
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if w.Body.String() != "users" {
+		t.Errorf("expected router to serve /users case-insensitively, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestCaseInsensitiveOpenRedirect(t *testing.T) {
+	sub := Declare(Default(), CaseInsensitive(true), HandleFunc(http.MethodGet, "/Foo", okHandler("foo")))
+	rt := Declare(Default())
+	if err := rt.Mount("/[tenant]", sub); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "//evil.com/foo", nil)
+	req.RequestURI = "//evil.com/foo"
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, req)
+	if loc := w.Header().Get("Location"); w.Code != http.StatusMovedPermanently || loc != "/evil.com/Foo" {
+		t.Errorf("expected redirect to /evil.com/Foo, got %d %q", w.Code, loc)
+	}
+}
+
+func TestCaseInsensitiveRoute(t *testing.T) {
+	rt := Declare(
+		Default(),
+		HandleRoute(route.Declare(http.MethodGet, "/Legacy/Default.aspx", route.CaseInsensitive()), okHandler("legacy")),
+		HandleFunc(http.MethodGet, "/Modern", okHandler("modern")),
+	)
+	s := httptest.NewServer(rt)
+	runEvalRequest(t, s, "/LEGACY/default.ASPX", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "legacy",
+	})
+	runEvalRequest(t, s, "/modern", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusNotFound,
+	})
+}
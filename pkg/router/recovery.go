@@ -0,0 +1,35 @@
+package router
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// A RecoveryFunc responds to a request whose handler or middleware panicked, with the recovered value and
+// the stack trace of the panic.
+//
+// If the response already started before the panic, w ignores new statuses, so a RecoveryFunc can always
+// call WriteHeader; anything it writes is appended to the partial response.
+type RecoveryFunc func(w http.ResponseWriter, req *http.Request, recovered any, stack []byte)
+
+// recoverInternalError responds to a panicking request with 500 Internal Server Error.
+func recoverInternalError(w http.ResponseWriter, req *http.Request, recovered any, stack []byte) {
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// recoverPanic recovers from a panic while serving req, and passes it to the table's recovery function.
+// http.ErrAbortHandler is panicked again, since handlers panic with it to abort the response on purpose.
+func (t *table) recoverPanic(w http.ResponseWriter, req *http.Request) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	if recovered == http.ErrAbortHandler {
+		panic(recovered)
+	}
+	if p, ok := recovered.(*routePanic); ok {
+		t.recovery(w, req, p.value, p.stack)
+		return
+	}
+	t.recovery(w, req, recovered, debug.Stack())
+}
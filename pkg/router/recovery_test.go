@@ -0,0 +1,148 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Return a handler that panics with v, after writing status code if it's nonzero.
+func panicHandler(code int, v any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if code != 0 {
+			w.WriteHeader(code)
+		}
+		panic(v)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	var recovered any
+	var stack []byte
+	rt := Declare(
+		Default(),
+		ImplicitHead(),
+		WithRecovery(func(w http.ResponseWriter, req *http.Request, rec any, st []byte) {
+			recovered, stack = rec, st
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("recovered"))
+		}),
+		HandleFunc(http.MethodGet, "/panic", panicHandler(0, "boom")),
+		HandleFunc(http.MethodGet, "/started", panicHandler(http.StatusAccepted, "late")),
+		HandleFunc(http.MethodGet, "/ok", okHandler("ok")),
+	)
+	s := httptest.NewServer(rt)
+	runEvalRequest(t, s, "/panic", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusInternalServerError,
+		"body": "recovered",
+	})
+	if recovered != "boom" {
+		t.Errorf("expected recovered value boom, got %v", recovered)
+	}
+	if !strings.Contains(string(stack), "panicHandler") {
+		t.Errorf("expected stack to include the panicking handler, got %s", stack)
+	}
+	// The status was already sent, so it isn't replaced.
+	runEvalRequest(t, s, "/started", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusAccepted,
+		"body": "recovered",
+	})
+	// Implicit HEAD responses don't write their status before recovery.
+	runEvalRequest(t, s, "/panic", reqGen(http.MethodHead), map[string]any{
+		"code": http.StatusInternalServerError,
+	})
+	runEvalRequest(t, s, "/ok", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "ok",
+	})
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	rt := Declare(
+		Default(),
+		WithRecovery(nil),
+		WithMiddleware(func(w http.ResponseWriter, r *http.Request) *http.Request {
+			if r.URL.Path == "/mw" {
+				panic("middleware")
+			}
+			return r
+		}),
+		HandleFunc(http.MethodGet, "/[any]", rpHandler("any")),
+	)
+	s := httptest.NewServer(rt)
+	runEvalRequest(t, s, "/mw", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusInternalServerError,
+	})
+	runEvalRequest(t, s, "/value", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "value",
+	})
+}
+
+func TestRecoveryAbort(t *testing.T) {
+	rt := Declare(
+		Default(),
+		WithRecovery(func(w http.ResponseWriter, req *http.Request, rec any, st []byte) {
+			t.Error("expected ErrAbortHandler not to be recovered")
+		}),
+		HandleFunc(http.MethodGet, "/abort", panicHandler(0, http.ErrAbortHandler)),
+	)
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("expected ErrAbortHandler to be panicked again, got %v", rec)
+		}
+	}()
+	rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+}
+
+func TestRecoveryWriter(t *testing.T) {
+	rt := Declare(
+		Default(),
+		WithRecovery(nil),
+		HandleFunc(http.MethodGet, "/flush", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+			panic("after flush")
+		}),
+		HandleFunc(http.MethodGet, "/copy", func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := w.(io.ReaderFrom); !ok {
+				t.Error("expected writer to implement io.ReaderFrom")
+			}
+			io.Copy(w, strings.NewReader("copied"))
+		}),
+		HandleFunc(http.MethodGet, "/hijack", func(w http.ResponseWriter, r *http.Request) {
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			buf.WriteString("HTTP/1.1 202 Accepted\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+			buf.Flush()
+			panic("after hijack")
+		}),
+	)
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/flush", nil))
+	if !w.Flushed || w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Errorf("expected flushed 200 partial, got %d %q (flushed %t)", w.Code, w.Body.String(), w.Flushed)
+	}
+	s := httptest.NewServer(rt)
+	defer s.Close()
+	runEvalRequest(t, s, "/copy", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "copied",
+	})
+	// The connection belongs to the handler once it's hijacked, so recovery doesn't write to it.
+	runEvalRequest(t, s, "/hijack", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusAccepted,
+		"body": "hijacked",
+	})
+	// Writers that can't flush or hijack are still wrapped without failing.
+	rt.ServeHTTP(&mockResponseWriter{}, httptest.NewRequest(http.MethodGet, "/flush", nil))
+	if _, _, err := (&recoveryWriter{ResponseWriter: &mockResponseWriter{}}).Hijack(); err != http.ErrNotSupported {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
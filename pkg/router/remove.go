@@ -0,0 +1,94 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/decentplatforms/matcha/pkg/route"
+)
+
+// ErrUnknownRoute is returned when no route has the requested method and expression.
+var ErrUnknownRoute = errors.New("no route")
+
+// hashOf gets the hash of the route a method and expression would create.
+func hashOf(method, expr string) (string, error) {
+	r, err := route.New(method, expr)
+	if err != nil {
+		return "", err
+	}
+	return r.Hash(), nil
+}
+
+// find gets the leaf IDs of the routes with the same hash as a method and expression.
+func (t *table) find(method, expr string) ([]int, error) {
+	hash, err := hashOf(method, expr)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, id := range t.ids() {
+		if t.routes[id].Hash() == hash {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w %s", ErrUnknownRoute, hash)
+	}
+	return ids, nil
+}
+
+// editRoutes finds the routes with a method and expression, and calls fn on each of their leaf IDs in one edit.
+func (rt *defaultRouter) editRoutes(method, expr string, fn func(t *table, id int)) error {
+	var err error
+	rt.edit(func(t *table) {
+		var ids []int
+		if ids, err = t.find(method, expr); err != nil {
+			return
+		}
+		for _, id := range ids {
+			fn(t, id)
+		}
+	})
+	return err
+}
+
+// Remove the routes with a method and expression.
+// Mounted handlers can be removed by the partial expression used to mount them, like "/api/+".
+//
+// See interface Router.
+func (rt *defaultRouter) Remove(method, expr string) error {
+	return rt.editRoutes(method, expr, (*table).unregister)
+}
+
+// unregister removes the route with leaf ID id from the table.
+func (t *table) unregister(id int) {
+	t.rtree.Remove(id)
+	delete(t.routes, id)
+	delete(t.handlers, id)
+	delete(t.mounts, id)
+	delete(t.disabled, id)
+}
+
+// Disable the routes with a method and expression.
+//
+// See interface Router.
+func (rt *defaultRouter) Disable(method, expr string, code int) error {
+	if code < 400 || code > 599 {
+		return fmt.Errorf("invalid status code %d: disabled routes must respond with an error", code)
+	}
+	return rt.editRoutes(method, expr, func(t *table, id int) {
+		t.disabled[id] = code
+		t.rtree.SetEnabled(id, code != http.StatusNotFound)
+	})
+}
+
+// Enable routes disabled by Disable.
+//
+// See interface Router.
+func (rt *defaultRouter) Enable(method, expr string) error {
+	return rt.editRoutes(method, expr, func(t *table, id int) {
+		delete(t.disabled, id)
+		t.rtree.SetEnabled(id, true)
+	})
+}
@@ -0,0 +1,130 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRemove(t *testing.T) {
+	rt := Declare(
+		Default(),
+		HandleFunc(http.MethodGet, "/users/[id]", rpHandler("id")),
+		HandleFunc(http.MethodGet, "/users/me", okHandler("me")),
+		HandleFunc(http.MethodPost, "/users//[id]", okHandler("post")),
+		Group("/v1", func(g Router) {
+			g.HandleFunc(http.MethodGet, "/status", okHandler("status"))
+		}),
+	)
+	if err := rt.Mount("/api", okHandler("api"), http.MethodGet); err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(rt)
+
+	if err := rt.Remove(http.MethodGet, "/users/[id]"); err != nil {
+		t.Fatal(err)
+	}
+	runEvalRequest(t, s, "/users/me", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "me",
+	})
+	// Expressions are normalized, like routes.
+	if err := rt.Remove(http.MethodPost, "/users/[id]"); err != nil {
+		t.Fatal(err)
+	}
+	runEvalRequest(t, s, "/users/1", reqGen(http.MethodPost), map[string]any{
+		"code": http.StatusNotFound,
+	})
+	if err := rt.Remove(http.MethodGet, "/api/+"); err != nil {
+		t.Fatal(err)
+	}
+	runEvalRequest(t, s, "/api/anything", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusNotFound,
+	})
+	if err := rt.Remove(http.MethodGet, "/v1/status"); err != nil {
+		t.Fatal(err)
+	}
+	if infos := rt.Routes(); len(infos) != 1 || infos[0].Expr != "/users/me" {
+		t.Errorf("expected only /users/me to remain, got %v", infos)
+	}
+	if err := rt.Remove(http.MethodGet, "/users/[id]"); !errors.Is(err, ErrUnknownRoute) {
+		t.Errorf("expected ErrUnknownRoute, got %v", err)
+	}
+	if err := rt.Remove(http.MethodGet, "/[bad]{(}"); err == nil || errors.Is(err, ErrUnknownRoute) {
+		t.Errorf("expected parse error, got %v", err)
+	}
+}
+
+func TestDisable(t *testing.T) {
+	rt := Declare(
+		Concurrent(),
+		HandleFunc(http.MethodGet, "/users/[id]", rpHandler("id")),
+		HandleFunc(http.MethodGet, "/users/me", okHandler("me")),
+		HandleFunc(http.MethodGet, "/legacy", okHandler("legacy")),
+		Group("/v1", func(g Router) {
+			g.HandleFunc(http.MethodGet, "/status", okHandler("status"))
+		}),
+	)
+	s := httptest.NewServer(rt)
+
+	// Routes disabled with 404 are skipped.
+	if err := rt.Disable(http.MethodGet, "/users/[id]", http.StatusNotFound); err != nil {
+		t.Fatal(err)
+	}
+	runEvalRequest(t, s, "/users/me", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "me",
+	})
+	runEvalRequest(t, s, "/users/1", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusNotFound,
+	})
+	// Routes disabled with other codes respond with them.
+	if err := rt.Disable(http.MethodGet, "/legacy", http.StatusServiceUnavailable); err != nil {
+		t.Fatal(err)
+	}
+	runEvalRequest(t, s, "/legacy", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusServiceUnavailable,
+	})
+	if infos := rt.Routes(); infos[0].Disabled != http.StatusNotFound || infos[2].Disabled != http.StatusServiceUnavailable || infos[1].Disabled != 0 {
+		t.Errorf("expected disabled codes in route info, got %v", infos)
+	}
+
+	if err := rt.Enable(http.MethodGet, "/users/[id]"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Enable(http.MethodGet, "/legacy"); err != nil {
+		t.Fatal(err)
+	}
+	runEvalRequest(t, s, "/users/1", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "1",
+	})
+	runEvalRequest(t, s, "/legacy", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "legacy",
+	})
+
+	if err := rt.Disable(http.MethodGet, "/nope", http.StatusNotFound); !errors.Is(err, ErrUnknownRoute) {
+		t.Errorf("expected ErrUnknownRoute, got %v", err)
+	}
+	// Disabled routes can only respond with errors; a success or redirect would look like the route still works.
+	for _, code := range []int{42, http.StatusOK, http.StatusNoContent, http.StatusFound, 399, 600, 999} {
+		if err := rt.Disable(http.MethodGet, "/legacy", code); err == nil {
+			t.Errorf("expected error for status code %d", code)
+		}
+	}
+	// A rejected code leaves the route enabled.
+	runEvalRequest(t, s, "/legacy", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "legacy",
+	})
+	var g Router
+	rt.Group("/v1", func(gr Router) { g = gr })
+	if err := g.Disable(http.MethodGet, "/status", http.StatusGone); err != nil {
+		t.Fatal(err)
+	}
+	runEvalRequest(t, s, "/v1/status", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusGone,
+	})
+}
@@ -6,6 +6,7 @@ package router
 import (
 	"net/http"
 
+	"github.com/decentplatforms/matcha/pkg/httperr"
 	"github.com/decentplatforms/matcha/pkg/middleware"
 	"github.com/decentplatforms/matcha/pkg/route"
 )
@@ -27,6 +28,10 @@ type Router interface {
 	// This constructs a basic Route internally. Returns an error if routing path rules are
 	// violated; see routes.md.
 	HandleFunc(method, path string, h http.HandlerFunc) error
+	// Handle a method and path with a handler that can return an error.
+	// Returned errors are reported to the router's ErrorHandler; see WithErrorHandler.
+	// Returns an error if routing path rules are violated, like Handle.
+	HandleE(method, path string, h httperr.HandlerFunc) error
 	// Handle a more complex path.
 	// If you're only using method+path, use Handle instead.
 	HandleRoute(r route.Route, h http.Handler)
@@ -38,10 +43,70 @@ type Router interface {
 	// of methods passed in the variadic methods parameter. Use this if you want to
 	// use your existing handler at a specific URI.
 	Mount(path string, h http.Handler, methods ...string) error
+	// Mount a handler at a path with options.
+	// Like Mount, but the mount can be configured with MountOptions; Mount(path, h, methods...) is equivalent to
+	// MountWith(path, h, MountMethods(methods...)).
+	MountWith(path string, h http.Handler, opts ...MountOption) error
+	// Remove the routes with a method and expression.
+	// The expression is normalized as if it were used to create a Route.
+	//
+	// Router implementations must keep the order of the remaining routes.
+	// Router implementations must return an error wrapping ErrUnknownRoute if no route matches.
+	Remove(method, expr string) error
+	// Disable the routes with a method and expression, without removing them.
+	// Disabled routes respond with the given status code, which must be a client or server error (4xx or 5xx).
+	// If the code is http.StatusNotFound, they are skipped instead, as if they had been removed.
+	//
+	// Router implementations must return an error wrapping ErrUnknownRoute if no route matches.
+	Disable(method, expr string, code int) error
+	// Enable routes disabled by Disable.
+	//
+	// Router implementations must return an error wrapping ErrUnknownRoute if no route matches.
+	Enable(method, expr string) error
 	// Add a handler for any request that is not matched.
 	//
 	// Router implementations should define default behavior, and must allow user assignment of behavior.
 	AddNotFound(h http.Handler)
+	// Add a handler for any request under a path prefix that is not matched, in place of the NotFound handler.
+	// The prefix may have params, like a route expression. If h is nil, the handler for the prefix is removed.
+	//
+	// Router implementations must use the handler for the longest matching prefix, and pass unmatched requests to
+	// mounted Routers without their own NotFound handler to the handler for the mount prefix.
+	AddNotFoundAt(prefix string, h http.Handler) error
+	// Add a handler for any request whose path is matched by a route, but not for the request method.
+	//
+	// Router implementations must set the Allow header to the matching methods before calling the handler.
+	// Router implementations should define default behavior, and must allow user assignment of behavior.
+	AddMethodNotAllowed(h http.Handler)
+	// Create a group of routes under a prefix, and call fn to register routes on it.
+	// Routes registered on the group are joined onto the prefix and get the group's configuration before
+	// their own, then registered on the Router directly. Returns the first error from creating the group
+	// or registering routes on it.
+	//
+	// Router implementations must match grouped routes like any other route, in the same order relative to other routes.
+	Group(prefix string, fn func(g Router), cfs ...route.ConfigFunc) error
+	// Get information about every route the router serves, in the order they are registered.
+	//
+	// Router implementations must include the routes of mounted Routers, as they would be seen by Walk.
+	Routes() []RouteInfo
+	// Call fn on information about every route the router serves, in the order they are registered.
+	// Walk stops and returns the error if fn returns an error.
+	//
+	// Router implementations must walk the routes of mounted Routers in place of the routes used to mount them,
+	// with the mount prefix applied.
+	Walk(fn func(RouteInfo) error) error
+	// Find the routes that the Router can never serve, in the order they are registered: routes that duplicate
+	// or are shadowed by routes matched before them, and routes with parts that can't match any path segment.
+	// Routes rejected in strict mode by methods that can't return errors, like HandleRoute, are reported after them.
+	//
+	// Router implementations must consider the order they match routes in, and may skip problems they can't
+	// detect, like routes shadowed by regex routes that match the same paths.
+	Validate() []Problem
+	// Build the path of the route with the given name, using params to fill in its route params.
+	//
+	// Router implementations must search mounted Routers, and include the mount prefix in their paths.
+	// Router implementations must return an error wrapping ErrUnknownName if no route has the name.
+	URL(name string, params map[string]string) (string, error)
 	// Implements http.Handler
 	ServeHTTP(w http.ResponseWriter, req *http.Request)
 }
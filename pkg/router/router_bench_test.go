@@ -1,6 +1,7 @@
 package router
 
 import (
+	"fmt"
 	"math/rand"
 	"net/http"
 	"testing"
@@ -80,3 +81,40 @@ func BenchmarkBasicRouter(b *testing.B) {
 		}
 	}
 }
+
+// Benchmark routes made of regex parts with params, where each part is matched once per request.
+func BenchmarkRegexParams(b *testing.B) {
+	rt := Declare(Default(),
+		HandleRoute(route.Declare(http.MethodGet, `/orgs/[org]{[a-z][a-z0-9-]*}/repos/[repo]{[\w.-]+}/commits/[sha]{[0-9a-f]{7,40}}`), rpHandler("sha")),
+		HandleRoute(route.Declare(http.MethodGet, `/orgs/[org]{[a-z][a-z0-9-]*}/repos/[repo]{[\w.-]+}/tags/[tag]{v\d+\.\d+\.\d+}`), rpHandler("tag")),
+	)
+	benchReqs := []*http.Request{
+		declareReq("/orgs/decent/repos/matcha/commits/4c745ad"),
+		declareReq("/orgs/decent/repos/matcha/tags/v1.2.3"),
+	}
+	mockWriter := &mockResponseWriter{}
+	for i := 0; i < b.N; i++ {
+		for _, r := range benchReqs {
+			rt.ServeHTTP(mockWriter, r)
+		}
+	}
+}
+
+// Benchmark match cache hits from many goroutines at once, which shouldn't contend with each other.
+func BenchmarkMatchCacheParallel(b *testing.B) {
+	cache := newMatchCache(1024)
+	paths := make([]string, 256)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("/health/%d", i)
+		cache.add(http.MethodGet, paths[i], i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := rand.Int(); pb.Next(); i++ {
+			if _, ok := cache.get(http.MethodGet, paths[i%len(paths)]); !ok {
+				b.Error("expected cache hit")
+				return
+			}
+		}
+	})
+}
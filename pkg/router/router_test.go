@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -15,6 +16,7 @@ import (
 	"github.com/decentplatforms/matcha/pkg/cors"
 	"github.com/decentplatforms/matcha/pkg/route/require"
 
+	"github.com/decentplatforms/matcha/pkg/middleware"
 	"github.com/decentplatforms/matcha/pkg/rctx"
 	"github.com/decentplatforms/matcha/pkg/route"
 )
@@ -463,8 +465,11 @@ func TestComposition(t *testing.T) {
 	w = httptest.NewRecorder()
 	req = httptest.NewRequest(http.MethodPost, "/api/hello", nil)
 	api2.ServeHTTP(w, req)
-	if w.Code != 404 {
-		t.Error(404, w.Code)
+	if w.Code != 405 {
+		t.Error(405, w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != http.MethodGet {
+		t.Error(http.MethodGet, allow)
 	}
 
 	// Pass through all methods
@@ -499,8 +504,272 @@ func TestComposition(t *testing.T) {
 		t.Error("expected failure due to route formatting")
 	}
 
-	err = api2.Mount("/api/[version]", api1)
+	err = api2.Mount("/api/[version]{(}", api1)
 	if err == nil {
 		t.Error("expected error due to route formatting")
 	}
 }
+
+func TestMountParams(t *testing.T) {
+	projects := Declare(
+		Default(),
+		HandleFunc(http.MethodGet, "/", rpHandler("org")),
+		HandleRouteFunc(route.Declare(http.MethodGet, "/[project]", route.Name("project")), func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(rctx.GetParam(r.Context(), "org") + "/" + rctx.GetParam(r.Context(), "project") + " " + r.URL.Path))
+		}),
+	)
+	rt := Default()
+	if err := rt.Mount("/orgs/[org]{[a-z]+}/projects", projects); err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(rt)
+	runEvalRequest(t, s, "/orgs/decent/projects/matcha", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "decent/matcha /matcha",
+	})
+	runEvalRequest(t, s, "/orgs/decent/projects", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "decent",
+	})
+	runEvalRequest(t, s, "/orgs/d3cent/projects/matcha", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusNotFound,
+	})
+
+	infos := rt.Routes()
+	if len(infos) != 2 || infos[1].Expr != "/orgs/[org]{[a-z]+}/projects/[project]" || !reflect.DeepEqual(infos[1].Params, []string{"org", "project"}) {
+		t.Errorf("expected mounted routes with prefix params, got %+v", infos)
+	}
+	if u, err := rt.URL("project", map[string]string{"org": "decent", "project": "matcha"}); err != nil || u != "/orgs/decent/projects/matcha" {
+		t.Errorf("expected /orgs/decent/projects/matcha, got %s %v", u, err)
+	}
+	if _, err := rt.URL("project", map[string]string{"org": "d3cent", "project": "matcha"}); err == nil {
+		t.Error("expected error for prefix param that doesn't match")
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	rt := Declare(
+		Default(),
+		HandleFunc(http.MethodGet, "/resource/[id]", okHandler("get")),
+		HandleFunc(http.MethodPut, "/resource/[id]", okHandler("put")),
+		HandleFunc(http.MethodDelete, "/resource/[id]{[0-9]+}", okHandler("delete")),
+		HandleRoute(route.Declare(
+			http.MethodPost, "/resource/[id]",
+			route.Require(require.Hosts("origin.com")),
+		), okHandler("post")),
+	)
+	s := httptest.NewServer(rt)
+	runEvalRequest(t, s, "/resource/abc", reqGen(http.MethodPatch), map[string]any{
+		"code":   http.StatusMethodNotAllowed,
+		"header": http.Header{"Allow": {"GET, PUT"}},
+	})
+	runEvalRequest(t, s, "/resource/123", reqGen(http.MethodPatch), map[string]any{
+		"code":   http.StatusMethodNotAllowed,
+		"header": http.Header{"Allow": {"DELETE, GET, PUT"}},
+	})
+	runEvalRequest(t, s, "/other", reqGen(http.MethodPatch), map[string]any{
+		"code": http.StatusNotFound,
+	})
+
+	rt = Declare(
+		Default(),
+		HandleFunc(http.MethodGet, "/", okHandler("get")),
+		WithMethodNotAllowed(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte("allowed: " + w.Header().Get("Allow")))
+		})),
+	)
+	s = httptest.NewServer(rt)
+	runEvalRequest(t, s, "/", reqGen(http.MethodPost), map[string]any{
+		"code": http.StatusMethodNotAllowed,
+		"body": "allowed: GET",
+	})
+}
+
+func TestImplicitHead(t *testing.T) {
+	rt := Declare(
+		Default(),
+		ImplicitHead(),
+		HandleFunc(http.MethodGet, "/items/[id]", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Item", rctx.GetParam(r.Context(), "id"))
+			w.Write([]byte("item body"))
+		}),
+		HandleFunc(http.MethodGet, "/sized", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "100")
+			w.WriteHeader(http.StatusAccepted)
+		}),
+		HandleRoute(route.Declare(http.MethodGet, "/reject", route.WithMiddleware(reject)), okHandler("never")),
+		HandleFunc(http.MethodGet, "/explicit", okHandler("get")),
+		HandleFunc(http.MethodHead, "/explicit", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Explicit", "true")
+		}),
+		HandleFunc(http.MethodPost, "/post", okHandler("post")),
+	)
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/items/abc", nil))
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("expected 200 with no body, got %d with body %q", w.Code, w.Body.String())
+	}
+	if id := w.Header().Get("X-Item"); id != "abc" {
+		t.Errorf("expected X-Item abc, got %q", id)
+	}
+	if cl := w.Header().Get("Content-Length"); cl != "9" {
+		t.Errorf("expected Content-Length 9, got %q", cl)
+	}
+
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/sized", nil))
+	if w.Code != http.StatusAccepted || w.Header().Get("Content-Length") != "100" {
+		t.Errorf("expected 202 with handler Content-Length, got %d, %q", w.Code, w.Header().Get("Content-Length"))
+	}
+
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/reject", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected middleware to apply; expected %d, got %d", http.StatusForbidden, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/explicit", nil))
+	if w.Header().Get("X-Explicit") != "true" {
+		t.Error("expected explicit HEAD route to take priority")
+	}
+
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/post", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/items/abc", nil))
+	if allow := w.Header().Get("Allow"); allow != "GET, HEAD" {
+		t.Errorf("expected Allow to include HEAD, got %q", allow)
+	}
+
+	// Without ImplicitHead, HEAD requests aren't handled by GET routes.
+	w = httptest.NewRecorder()
+	Declare(Default(), HandleFunc(http.MethodGet, "/", okHandler("get"))).
+		ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+func TestImplicitHeadWriter(t *testing.T) {
+	rt := Declare(
+		Default(),
+		ImplicitHead(),
+		HandleFunc(http.MethodGet, "/stream", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Stream", "true")
+			w.Write([]byte("first"))
+			w.(http.Flusher).Flush()
+			w.Write([]byte("second"))
+			w.WriteHeader(http.StatusAccepted)
+		}),
+		HandleFunc(http.MethodGet, "/hijack", func(w http.ResponseWriter, r *http.Request) {
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			buf.WriteString("HTTP/1.1 202 Accepted\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+			buf.Flush()
+		}),
+	)
+	// Flushing writes the status right away; the body is still dropped, and Content-Length isn't guessed.
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/stream", nil))
+	if !w.Flushed || w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("X-Stream") != "true" {
+		t.Errorf("expected flushed 200 with no body, got %d %q (flushed %t)", w.Code, w.Body.String(), w.Flushed)
+	}
+	if cl := w.Header().Get("Content-Length"); cl != "" {
+		t.Errorf("expected no Content-Length after flushing, got %q", cl)
+	}
+	s := httptest.NewServer(rt)
+	defer s.Close()
+	runEvalRequest(t, s, "/hijack", reqGen(http.MethodHead), map[string]any{
+		"code": http.StatusAccepted,
+	})
+	if _, _, err := (&headWriter{ResponseWriter: &mockResponseWriter{}}).Hijack(); err != http.ErrNotSupported {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestMostSpecific(t *testing.T) {
+	rt := Declare(
+		Default(),
+		HandleFunc(http.MethodGet, "/files/[filepath]+", rpHandler("filepath")),
+		HandleFunc(http.MethodGet, "/files/[name]", okHandler("name")),
+		MostSpecific(),
+		HandleFunc(http.MethodGet, "/files/latest", okHandler("latest")),
+	)
+	s := httptest.NewServer(rt)
+	runEvalRequest(t, s, "/files/latest", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "latest",
+	})
+	runEvalRequest(t, s, "/files/other", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "name",
+	})
+	runEvalRequest(t, s, "/files/a/b", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "/a/b",
+	})
+	if _, err := New(otherRouter{Default()}, MostSpecific()); err == nil {
+		t.Error("expected error for unsupported router")
+	}
+}
+
+func TestMaxParams(t *testing.T) {
+	// setParams sets n params with middleware, and records the first error.
+	setParams := func(n int, failed *error) middleware.Middleware {
+		return func(w http.ResponseWriter, r *http.Request) *http.Request {
+			for i := 0; i < n; i++ {
+				if err := rctx.SetParam(r.Context(), fmt.Sprintf("mw%d", i), "set"); err != nil && *failed == nil {
+					*failed = err
+				}
+			}
+			return r
+		}
+	}
+	var small, grown error
+	rt := Declare(
+		Default(),
+		WithMaxParams(2),
+		HandleRoute(route.Declare(http.MethodGet, "/small/[a]", route.WithMiddleware(setParams(2, &small))), rpHandler("mw0")),
+		// Routes always fit their own params.
+		HandleFunc(http.MethodGet, "/many/[a]/[b]/[c]", rpHandler("c")),
+	)
+	s := httptest.NewServer(rt)
+	runEvalRequest(t, s, "/small/x", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "set",
+	})
+	if !errors.Is(small, rctx.ErrOverCapacity) {
+		t.Errorf("expected over capacity error, got %v", small)
+	}
+	runEvalRequest(t, s, "/many/x/y/z", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "z",
+	})
+	grow := Declare(
+		Default(),
+		WithMaxParams(1),
+		GrowParams(),
+		HandleRoute(route.Declare(http.MethodGet, "/grow/[a]", route.WithMiddleware(setParams(5, &grown))), rpHandler("mw4")),
+	)
+	runEvalRequest(t, httptest.NewServer(grow), "/grow/x", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "set",
+	})
+	if grown != nil {
+		t.Errorf("expected params to grow, got %v", grown)
+	}
+	if err := WithMaxParams(-1)(Default()); err == nil {
+		t.Error("expected negative max params to fail")
+	}
+}
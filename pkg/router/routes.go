@@ -0,0 +1,122 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/decentplatforms/matcha/pkg/path"
+	"github.com/decentplatforms/matcha/pkg/route"
+	"github.com/decentplatforms/matcha/pkg/route/require"
+)
+
+// RouteInfo describes a route served by a Router.
+type RouteInfo struct {
+	// The method of the route.
+	Method string
+	// The expression of the route, including the prefixes of any Routers it is mounted under.
+	// Empty if the route isn't a route.Exprer.
+	Expr string
+	// The order the route was registered in on its Router, starting at 1.
+	Order int
+	// The names of the params set by the route, including those set by mount prefixes.
+	Params []string
+	// The number of middleware attached to the route.
+	Middleware int
+	// The requirements attached to the route.
+	Required []require.Required
+	// The status code the route responds with if it is disabled, or 0 if it is enabled.
+	Disabled int
+	// The prefix the route's Router is mounted at, or "" if the route is registered directly.
+	Mount string
+	// The route itself. For routes of mounted Routers, this doesn't include the mount prefix.
+	Route route.Route
+}
+
+// mount records a handler mounted at a prefix.
+type mount struct {
+	prefix  string
+	r       route.Route
+	h       http.Handler
+	methods []string
+}
+
+// walker is implemented by handlers that can be walked like a Router.
+type walker interface {
+	Walk(fn func(RouteInfo) error) error
+}
+
+// has checks if a mount includes a method.
+func (m *mount) has(method string) bool {
+	for _, mm := range m.methods {
+		if mm == method {
+			return true
+		}
+	}
+	return false
+}
+
+// walk calls fn on the routes of a mounted Router, with the mount prefix applied.
+func (m *mount) walk(sub walker, fn func(RouteInfo) error) error {
+	params := route.Params(m.r)
+	return sub.Walk(func(info RouteInfo) error {
+		if !m.has(info.Method) {
+			return nil
+		}
+		info.Expr = path.Join(m.prefix, info.Expr)
+		info.Mount = path.Join(m.prefix, info.Mount)
+		if len(params) > 0 {
+			info.Params = append(append([]string{}, params...), info.Params...)
+		}
+		return fn(info)
+	})
+}
+
+// Get information about every route the router serves.
+//
+// See interface Router.
+func (rt *defaultRouter) Routes() []RouteInfo {
+	var infos []RouteInfo
+	rt.Walk(func(info RouteInfo) error {
+		infos = append(infos, info)
+		return nil
+	})
+	return infos
+}
+
+// Call fn on information about every route the router serves.
+// Routes are walked in registration order. Mounted Routers are walked once, at the position of the first
+// route used to mount them; mounted handlers that aren't Routers are walked as their mount routes.
+//
+// See interface Router.
+func (rt *defaultRouter) Walk(fn func(RouteInfo) error) error {
+	t := rt.tbl.Load()
+	walked := make(map[*mount]bool)
+	for _, id := range t.ids() {
+		if m, ok := t.mounts[id]; ok {
+			if sub, ok := m.h.(walker); ok {
+				if walked[m] {
+					continue
+				}
+				walked[m] = true
+				if err := m.walk(sub, fn); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		r := t.routes[id]
+		err := fn(RouteInfo{
+			Method:     r.Method(),
+			Expr:       route.ExprOf(r),
+			Order:      id,
+			Params:     route.Params(r),
+			Middleware: len(r.Middleware()),
+			Required:   r.Required(),
+			Disabled:   t.disabled[id],
+			Route:      r,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/decentplatforms/matcha/pkg/middleware"
+	"github.com/decentplatforms/matcha/pkg/route"
+	"github.com/decentplatforms/matcha/pkg/route/require"
+)
+
+func TestRoutes(t *testing.T) {
+	api := Declare(
+		Default(),
+		HandleFunc(http.MethodGet, "/users/[id]", okHandler("get")),
+		HandleFunc(http.MethodPost, "/users", okHandler("post")),
+	)
+	rt := Declare(
+		Default(),
+		HandleRoute(route.Declare(http.MethodGet, "/", route.Require(require.Hosts("decentplatforms.com"))), okHandler("root")),
+		HandleRoute(route.Declare(http.MethodGet, "/files/[name]+", route.WithMiddleware(middleware.ExpectHeader("X-Test"))), okHandler("files")),
+	)
+	if err := rt.Mount("/api", api, http.MethodGet); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Mount("/static", http.FileServer(http.Dir(".")), http.MethodGet); err != nil {
+		t.Fatal(err)
+	}
+	infos := rt.Routes()
+	expected := []RouteInfo{
+		{Method: http.MethodGet, Expr: "/", Order: 1},
+		{Method: http.MethodGet, Expr: "/files/[name]+", Order: 2, Params: []string{"name"}, Middleware: 1},
+		{Method: http.MethodGet, Expr: "/api/users/[id]", Order: 1, Params: []string{"id"}, Mount: "/api"},
+		{Method: http.MethodGet, Expr: "/static/+", Order: 4, Middleware: 1},
+	}
+	if len(infos) != len(expected) {
+		t.Fatalf("expected %d routes, got %d: %v", len(expected), len(infos), infos)
+	}
+	for i, info := range infos {
+		exp := expected[i]
+		if info.Method != exp.Method || info.Expr != exp.Expr || info.Order != exp.Order ||
+			info.Middleware != exp.Middleware || info.Mount != exp.Mount || !reflect.DeepEqual(info.Params, exp.Params) {
+			t.Errorf("route %d: expected %+v, got %+v", i, exp, info)
+		}
+		if info.Route == nil {
+			t.Errorf("route %d: expected route to be set", i)
+		}
+	}
+	if len(infos[0].Required) != 1 {
+		t.Errorf("expected 1 requirement, got %d", len(infos[0].Required))
+	}
+
+	stop := errors.New("stop")
+	ct := 0
+	err := rt.Walk(func(info RouteInfo) error {
+		ct++
+		if info.Mount != "" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || ct != 3 {
+		t.Errorf("expected walk to stop at the third route, got %v after %d", err, ct)
+	}
+}
+
+// exprlessRoute is a Route without an expression.
+type exprlessRoute struct {
+	route.Route
+}
+
+func TestRoutesWithoutExpr(t *testing.T) {
+	r := exprlessRoute{route.Declare(http.MethodGet, "/plain")}
+	rt := Declare(Default(), HandleRoute(r, okHandler("plain")))
+	infos := rt.Routes()
+	if len(infos) != 1 || infos[0].Expr != "" || infos[0].Route != route.Route(r) {
+		t.Errorf("expected route without an expression, got %+v", infos)
+	}
+	p := Problem{Kind: Unreachable, Route: r}
+	if msg := p.Error(); !strings.Contains(msg, r.Hash()) {
+		t.Errorf("expected problem to describe the route by its hash, got %s", msg)
+	}
+}
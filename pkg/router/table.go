@@ -0,0 +1,195 @@
+package router
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/decentplatforms/matcha/pkg/cors"
+	"github.com/decentplatforms/matcha/pkg/httperr"
+	"github.com/decentplatforms/matcha/pkg/middleware"
+	"github.com/decentplatforms/matcha/pkg/rctx"
+	"github.com/decentplatforms/matcha/pkg/route"
+	"github.com/decentplatforms/matcha/pkg/tree"
+)
+
+// table is a snapshot of the routing state of a defaultRouter.
+// Requests are served from a single table, so concurrent Routers can swap in a new table without affecting
+// requests in flight.
+type table struct {
+	mws        []middleware.Middleware
+	routes     map[int]route.Route
+	rtree      *tree.RouteTree
+	handlers   map[int]http.Handler
+	mounts     map[int]*mount
+	disabled   map[int]int
+	notfound   http.Handler
+	scopes     *tree.RouteTree
+	scoped     map[int]*scope
+	notallowed http.Handler
+	recovery   RecoveryFunc
+	errors     httperr.ErrorHandler
+	timeout    time.Duration
+	onTimeout  http.Handler
+	cache      *matchCache
+	// options
+	autoOptions  bool
+	pathMode     PathMode
+	maxParams    int
+	routeParams  int
+	growParams   bool
+	fold         bool
+	foldRedirect bool
+	strict       bool
+	rejected     []Problem
+	cors         *cors.AccessControlOptions
+}
+
+// Create an empty table.
+func newTable() *table {
+	return &table{
+		mws:        make([]middleware.Middleware, 0),
+		routes:     make(map[int]route.Route),
+		rtree:      tree.New(),
+		handlers:   make(map[int]http.Handler),
+		mounts:     make(map[int]*mount),
+		disabled:   make(map[int]int),
+		maxParams:  rctx.DefaultMaxParams,
+		notallowed: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusMethodNotAllowed) }),
+		onTimeout:  http.HandlerFunc(timeoutServiceUnavailable),
+	}
+}
+
+// clone copies the table, so it can be edited without affecting the original.
+func (t *table) clone() *table {
+	c := *t
+	c.mws = append(make([]middleware.Middleware, 0, len(t.mws)), t.mws...)
+	c.rtree = t.rtree.Clone()
+	c.routes = make(map[int]route.Route, len(t.routes))
+	for id, r := range t.routes {
+		c.routes[id] = r
+	}
+	c.handlers = make(map[int]http.Handler, len(t.handlers))
+	for id, h := range t.handlers {
+		c.handlers[id] = h
+	}
+	c.mounts = make(map[int]*mount, len(t.mounts))
+	for id, m := range t.mounts {
+		c.mounts[id] = m
+	}
+	c.disabled = make(map[int]int, len(t.disabled))
+	for id, code := range t.disabled {
+		c.disabled[id] = code
+	}
+	c.rejected = append([]Problem(nil), t.rejected...)
+	// The copy gets an empty cache, since it's about to be edited.
+	c.cache = t.cache.empty()
+	if t.scopes != nil {
+		c.scopes = t.scopes.Clone()
+		c.scoped = make(map[int]*scope, len(t.scoped))
+		for id, s := range t.scoped {
+			c.scoped[id] = s
+		}
+	}
+	return &c
+}
+
+// register adds a route and its handler to the table.
+// If the table is case-insensitive, a case-insensitive copy of the route is added, so r isn't changed.
+// Returns the leaf ID of the route.
+func (t *table) register(r route.Route, h http.Handler) int {
+	if t.fold {
+		r = route.Fold(r)
+	}
+	id := t.rtree.Add(r)
+	t.routes[id] = r
+	if n := route.NumParams(r); n > t.routeParams {
+		t.routeParams = n
+	}
+	if h != nil {
+		t.handlers[id] = h
+	} else {
+		t.handlers[id] = nil
+	}
+	return id
+}
+
+// paramCapacity gets the number of params to allow for requests matched by r: enough for its own params,
+// and at least the table's maximum, so middleware and handlers can set params too.
+func (t *table) paramCapacity(r route.Route) int {
+	if n := route.NumParams(r); n > t.maxParams {
+		return n
+	}
+	return t.maxParams
+}
+
+// bindCapacity gets the number of params to allocate for requests before they're matched: enough for the params
+// of any route, and at least the table's maximum, so the context can be sized for the matched route in place.
+func (t *table) bindCapacity() int {
+	if t.routeParams > t.maxParams {
+		return t.routeParams
+	}
+	return t.maxParams
+}
+
+// ids gets the leaf IDs of the table's routes in registration order.
+func (t *table) ids() []int {
+	ids := make([]int, 0, len(t.routes))
+	for id := range t.routes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// edit applies changes to the router's table.
+// Concurrent Routers copy the table, edit the copy, and swap it in, so requests are never served from a
+// partially edited table; edits are serialized. Other Routers edit the table in place, and drop the matches
+// cached before the edit.
+func (rt *defaultRouter) edit(fn func(t *table)) {
+	if !rt.concurrent {
+		t := rt.tbl.Load()
+		fn(t)
+		t.cache.reset()
+		return
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	t := rt.tbl.Load().clone()
+	fn(t)
+	rt.tbl.Store(t)
+}
+
+// Create a Router that is safe to register routes on while it serves requests.
+// Each change to the Router copies its routing table and swaps in the edited copy, so requests in flight are
+// served from the table they started with. Since each change copies the table, registering many routes is
+// slower than with Default; use Reload to build and swap in a whole table at once.
+//
+// Routes must not be modified once they are registered on a concurrent Router.
+func Concurrent() *defaultRouter {
+	rt := Default()
+	rt.concurrent = true
+	return rt
+}
+
+// Replace the routing table of a Router with a new one built from cfs.
+// The new table starts empty, as if cfs were passed to a new Router, and is swapped in once every ConfigFunc
+// succeeds; if one fails, the Router is unchanged. Requests in flight are served from the table they started with.
+//
+// Reload is only supported by Routers created with Default or Concurrent.
+func Reload(rt Router, cfs ...ConfigFunc) error {
+	drt, err := asDefault(rt, "Reload")
+	if err != nil {
+		return err
+	}
+	staging := Default()
+	for _, cf := range cfs {
+		if err := cf(staging); err != nil {
+			return err
+		}
+	}
+	drt.mu.Lock()
+	defer drt.mu.Unlock()
+	drt.tbl.Store(staging.tbl.Load())
+	return nil
+}
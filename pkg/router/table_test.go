@@ -0,0 +1,109 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentRouter(t *testing.T) {
+	rt := Declare(
+		Concurrent(),
+		HandleFunc(http.MethodGet, "/static", okHandler("static")),
+	)
+	s := httptest.NewServer(rt)
+	defer s.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				w := httptest.NewRecorder()
+				rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static", nil))
+				if w.Code != http.StatusOK {
+					t.Errorf("expected 200, got %d", w.Code)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		if err := rt.HandleFunc(http.MethodGet, fmt.Sprintf("/plugin/%d", i), okHandler("plugin")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	runEvalRequest(t, s, "/plugin/49", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "plugin",
+	})
+	if ct := len(rt.Routes()); ct != 51 {
+		t.Errorf("expected 51 routes, got %d", ct)
+	}
+}
+
+func TestReload(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("old"))
+	}
+	rt := Declare(
+		Concurrent(),
+		HandleFunc(http.MethodGet, "/v1", slow),
+	)
+	s := httptest.NewServer(rt)
+	defer s.Close()
+
+	// Requests in flight finish with the table they started with.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runEvalRequest(t, s, "/v1", reqGen(http.MethodGet), map[string]any{
+			"code": http.StatusOK,
+			"body": "old",
+		})
+	}()
+	<-started
+	err := Reload(rt,
+		HandleFunc(http.MethodGet, "/v2", okHandler("new")),
+		WithNotFound(nfHandler()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	<-done
+
+	runEvalRequest(t, s, "/v2", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "new",
+	})
+	runEvalRequest(t, s, "/v1", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusNotFound,
+		"body": "not found",
+	})
+
+	// Failed reloads leave the table unchanged.
+	fail := errors.New("fail")
+	err = Reload(rt,
+		HandleFunc(http.MethodGet, "/v3", okHandler("v3")),
+		func(rt Router) error { return fail },
+	)
+	if err != fail {
+		t.Errorf("expected reload to fail, got %v", err)
+	}
+	runEvalRequest(t, s, "/v2", reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "new",
+	})
+	if err := Reload(otherRouter{Default()}); err == nil {
+		t.Error("expected error reloading an unsupported router")
+	}
+}
@@ -0,0 +1,160 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/decentplatforms/matcha/pkg/rctx"
+	"github.com/decentplatforms/matcha/pkg/route"
+)
+
+// timeoutServiceUnavailable responds to requests that time out with 503 Service Unavailable.
+func timeoutServiceUnavailable(w http.ResponseWriter, req *http.Request) {
+	w.WriteHeader(http.StatusServiceUnavailable)
+}
+
+// timeoutWriter buffers the response of a handler with a timeout, so it can be dropped if the handler doesn't
+// finish in time. Once the request's deadline passes, writes fail with http.ErrHandlerTimeout.
+type timeoutWriter struct {
+	ctx      context.Context
+	w        http.ResponseWriter
+	h        http.Header
+	buf      bytes.Buffer
+	mu       sync.Mutex
+	code     int
+	timedOut bool
+}
+
+// Header gets the headers of the buffered response.
+// Once the request times out, the handler gets a detached copy, since the buffered response is never written.
+func (tw *timeoutWriter) Header() http.Header {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() {
+		return tw.h.Clone()
+	}
+	return tw.h
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+// expired checks if the request timed out, and stops the handler from writing if it did. tw.mu must be held.
+func (tw *timeoutWriter) expired() bool {
+	if !tw.timedOut && tw.ctx.Err() == context.DeadlineExceeded {
+		tw.timedOut = true
+	}
+	return tw.timedOut
+}
+
+// timeout stops the handler from writing to the response.
+func (tw *timeoutWriter) timeout() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.timedOut = true
+}
+
+// finish writes the buffered response once the handler is done.
+// Returns false without writing if the handler timed out while writing.
+func (tw *timeoutWriter) finish() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return false
+	}
+	// Values are copied, so the response doesn't share them with goroutines the handler left running.
+	dst := tw.w.Header()
+	for k, v := range tw.h {
+		dst[k] = append([]string(nil), v...)
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	tw.w.WriteHeader(tw.code)
+	tw.w.Write(tw.buf.Bytes())
+	return true
+}
+
+// routePanic is a panic in a route served in its own goroutine, passed back to the goroutine serving the request
+// for the table's RecoveryFunc. It keeps the stack of the route's goroutine, so RecoveryFuncs get the stack of
+// the panic.
+type routePanic struct {
+	value any
+	stack []byte
+}
+
+// String describes the panic with the route's stack, for panics that aren't recovered.
+func (p *routePanic) String() string {
+	return fmt.Sprintf("%v\n\n%s", p.value, p.stack)
+}
+
+// routeTimeout gets the timeout for requests to a route; the route's own timeout takes priority over the default.
+func (t *table) routeTimeout(r route.Route) time.Duration {
+	if d, ok := route.GetTimeout(r); ok {
+		return d
+	}
+	return t.timeout
+}
+
+// serveTimeout serves a request with the route with leaf ID leaf_id, and responds with the timeout handler if the
+// route isn't done within d.
+// The route is served in its own goroutine, which returns the request context once it's done, so a route that
+// outlives its timeout never shares a pooled context. Panics in the route are passed back to the caller. If the
+// table has a RecoveryFunc, they're passed as a *routePanic with the stack of the route's goroutine, except
+// http.ErrAbortHandler; otherwise they're passed back as is, so recovery outside the Router gets the original value.
+func (t *table) serveTimeout(w http.ResponseWriter, req *http.Request, leaf_id int, rc *rctx.Context, d time.Duration) {
+	ctx, cancel := context.WithTimeout(req.Context(), d)
+	defer cancel()
+	tw := &timeoutWriter{ctx: ctx, w: w, h: make(http.Header)}
+	done := make(chan struct{})
+	panicked := make(chan any, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				if t.recovery != nil && p != http.ErrAbortHandler {
+					p = &routePanic{value: p, stack: debug.Stack()}
+				}
+				panicked <- p
+				return
+			}
+			close(done)
+		}()
+		t.serveHandler(tw, req.WithContext(ctx), leaf_id, rc)
+	}()
+	select {
+	case p := <-panicked:
+		panic(p)
+	case <-done:
+		if tw.finish() {
+			return
+		}
+	case <-ctx.Done():
+		tw.timeout()
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		t.onTimeout.ServeHTTP(w, req)
+	}
+}
@@ -0,0 +1,187 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decentplatforms/matcha/pkg/rctx"
+	"github.com/decentplatforms/matcha/pkg/route"
+)
+
+// slowHandler waits until the request is done, then sends the error of writing the response on errs.
+func slowHandler(errs chan<- error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		_, err := w.Write([]byte("late"))
+		errs <- err
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	errs := make(chan error, 1)
+	rt := Declare(
+		Default(),
+		WithTimeout(20*time.Millisecond),
+		HandleFunc(http.MethodGet, "/slow", slowHandler(errs)),
+		HandleFunc(http.MethodGet, "/users/[id]", func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Deadline(); !ok {
+				t.Error("expected request context to have a deadline")
+			}
+			w.Header().Set("X-Id", rctx.GetParam(r.Context(), "id"))
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("user"))
+		}),
+		Group("/reports", func(g Router) {
+			g.HandleFunc(http.MethodGet, "/", func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(40 * time.Millisecond)
+				w.Write([]byte("report"))
+			})
+		}, route.Timeout(time.Second)),
+		HandleRouteFunc(route.Declare(http.MethodGet, "/stream", route.Timeout(0)), func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Deadline(); ok {
+				t.Error("expected route without timeout to have no deadline")
+			}
+			w.Write([]byte("stream"))
+		}),
+	)
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "" {
+		t.Errorf("expected empty 503, got %d %q", w.Code, w.Body.String())
+	}
+	if err := <-errs; err != http.ErrHandlerTimeout {
+		t.Errorf("expected late write to fail with ErrHandlerTimeout, got %v", err)
+	}
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/users/12", http.StatusCreated, "user"},
+		{"/reports", http.StatusOK, "report"},
+		{"/stream", http.StatusOK, "stream"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if w.Code != test.code || w.Body.String() != test.body {
+			t.Errorf("%s: expected %d %q, got %d %q", test.path, test.code, test.body, w.Code, w.Body.String())
+		}
+	}
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/12", nil))
+	if id := w.Header().Get("X-Id"); id != "12" {
+		t.Errorf("expected buffered header X-Id: 12, got %q", id)
+	}
+	if err := WithTimeout(-time.Second)(Default()); err == nil {
+		t.Error("expected negative timeout to fail")
+	}
+}
+
+func TestTimeoutHandler(t *testing.T) {
+	errs := make(chan error, 1)
+	rt := Declare(
+		Default(),
+		ImplicitHead(),
+		WithTimeoutHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusGatewayTimeout)
+			w.Write([]byte("timed out"))
+		})),
+		HandleRouteFunc(route.Declare(http.MethodGet, "/slow", route.Timeout(10*time.Millisecond)), slowHandler(errs)),
+	)
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	<-errs
+	if w.Code != http.StatusGatewayTimeout || w.Body.String() != "timed out" {
+		t.Errorf("expected 504 \"timed out\", got %d %q", w.Code, w.Body.String())
+	}
+	// HEAD requests to GET routes time out the same way.
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/slow", nil))
+	<-errs
+	if w.Code != http.StatusGatewayTimeout || w.Body.Len() != 0 {
+		t.Errorf("expected 504 without a body, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	var recovered any
+	var stack []byte
+	rt := Declare(
+		Default(),
+		WithRecovery(func(w http.ResponseWriter, req *http.Request, rec any, st []byte) {
+			recovered, stack = rec, st
+			w.WriteHeader(http.StatusInternalServerError)
+		}),
+		WithTimeout(time.Second),
+		HandleFunc(http.MethodGet, "/panic", panicHandler(http.StatusOK, "boom")),
+	)
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected panic in route with timeout to be recovered, got %d", w.Code)
+	}
+	// The recovery function gets the panic as it was, with the stack of the route's goroutine.
+	if recovered != "boom" {
+		t.Errorf("expected recovered value boom, got %v", recovered)
+	}
+	if !strings.Contains(string(stack), "panicHandler") {
+		t.Errorf("expected stack to include the panicking handler, got %s", stack)
+	}
+}
+
+func TestTimeoutPanicWithoutRecovery(t *testing.T) {
+	rt := Declare(
+		Default(),
+		WithTimeout(time.Second),
+		HandleFunc(http.MethodGet, "/panic", panicHandler(http.StatusOK, "boom")),
+		HandleFunc(http.MethodGet, "/abort", func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}),
+	)
+	// Without a RecoveryFunc, recovery outside the Router gets the value the route panicked with.
+	for p, expected := range map[string]any{"/panic": "boom", "/abort": http.ErrAbortHandler} {
+		func() {
+			defer func() {
+				if rec := recover(); rec != expected {
+					t.Errorf("%s: expected panic %v, got %v", p, expected, rec)
+				}
+			}()
+			rt.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+		}()
+	}
+}
+
+func TestTimeoutHeader(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	rt := Declare(
+		Default(),
+		WithTimeout(10*time.Millisecond),
+		HandleFunc(http.MethodGet, "/late", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Early", "true")
+			<-r.Context().Done()
+			// Headers set after the timeout don't reach the response.
+			w.Header().Set("X-Late", "true")
+			headers <- w.Header()
+		}),
+		HandleFunc(http.MethodGet, "/ok", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Ok", "true")
+		}),
+	)
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/late", nil))
+	if h := <-headers; h.Get("X-Early") != "true" {
+		t.Errorf("expected detached header to keep earlier values, got %v", h)
+	}
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("X-Early") != "" || w.Header().Get("X-Late") != "" {
+		t.Errorf("expected 503 without the route's headers, got %d %v", w.Code, w.Header())
+	}
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if w.Header().Get("X-Ok") != "true" {
+		t.Errorf("expected route's headers, got %v", w.Header())
+	}
+}
@@ -0,0 +1,51 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/decentplatforms/matcha/pkg/path"
+	"github.com/decentplatforms/matcha/pkg/route"
+)
+
+// ErrUnknownName is returned by URL when no route has the requested name.
+var ErrUnknownName = errors.New("no route with name")
+
+// urlBuilder is implemented by handlers that can build URLs like a Router.
+type urlBuilder interface {
+	URL(name string, params map[string]string) (string, error)
+}
+
+// Build the path of the route with the given name.
+// Routes are searched in registration order, including the routes of mounted Routers, and the first route
+// with the name is used.
+//
+// See interface Router.
+func (rt *defaultRouter) URL(name string, params map[string]string) (string, error) {
+	t := rt.tbl.Load()
+	searched := make(map[*mount]bool)
+	for _, id := range t.ids() {
+		if m, ok := t.mounts[id]; ok {
+			sub, ok := m.h.(urlBuilder)
+			if !ok || searched[m] {
+				continue
+			}
+			searched[m] = true
+			u, err := sub.URL(name, params)
+			if errors.Is(err, ErrUnknownName) {
+				continue
+			} else if err != nil {
+				return "", err
+			}
+			prefix, err := route.Reverse(m.r, params)
+			if err != nil {
+				return "", err
+			}
+			return path.Join(prefix, u), nil
+		}
+		if r := t.routes[id]; route.GetName(r) == name {
+			return route.Reverse(r, params)
+		}
+	}
+	return "", fmt.Errorf("%w %s", ErrUnknownName, name)
+}
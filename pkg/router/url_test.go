@@ -0,0 +1,69 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/decentplatforms/matcha/pkg/route"
+)
+
+func TestURL(t *testing.T) {
+	api := Declare(
+		Default(),
+		HandleRoute(route.Declare(http.MethodGet, "/users/[id]{[0-9]+}/posts", route.Name("user.posts")), okHandler("posts")),
+		HandleRoute(route.Declare(http.MethodGet, "/", route.Name("api.root")), okHandler("root")),
+	)
+	rt := Declare(
+		Default(),
+		HandleRoute(route.Declare(http.MethodGet, "/files/[path]+", route.Name("files")), rpHandler("path")),
+		HandleRoute(route.Declare(http.MethodGet, "/users/[id]", route.Name("user")), rpHandler("id")),
+	)
+	if err := rt.Mount("/api/v1", api); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		params map[string]string
+		url    string
+	}{
+		{"files", map[string]string{"path": "docs/read me.md"}, "/files/docs/read%20me.md"},
+		{"user", map[string]string{"id": "a/b"}, "/users/a%2Fb"},
+		{"user.posts", map[string]string{"id": "12"}, "/api/v1/users/12/posts"},
+		{"api.root", nil, "/api/v1"},
+	}
+	for _, test := range tests {
+		u, err := rt.URL(test.name, test.params)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		} else if u != test.url {
+			t.Errorf("%s: expected %s, got %s", test.name, test.url, u)
+		}
+	}
+
+	// Generated URLs route back to the same handlers.
+	s := httptest.NewServer(rt)
+	u, _ := rt.URL("files", map[string]string{"path": "docs/read me.md"})
+	runEvalRequest(t, s, u, reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "/docs/read me.md",
+	})
+	u, _ = rt.URL("user.posts", map[string]string{"id": "12"})
+	runEvalRequest(t, s, u, reqGen(http.MethodGet), map[string]any{
+		"code": http.StatusOK,
+		"body": "posts",
+	})
+
+	if _, err := rt.URL("user.posts", map[string]string{"id": "twelve"}); err == nil || !strings.Contains(err.Error(), `"twelve" does not match`) {
+		t.Errorf("expected mismatch error, got %v", err)
+	}
+	if _, err := rt.URL("user", nil); err == nil || !strings.Contains(err.Error(), "missing param id") {
+		t.Errorf("expected missing param error, got %v", err)
+	}
+	if _, err := rt.URL("nope", nil); !errors.Is(err, ErrUnknownName) {
+		t.Errorf("expected ErrUnknownName, got %v", err)
+	}
+}
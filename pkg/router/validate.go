@@ -0,0 +1,187 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/decentplatforms/matcha/pkg/route"
+	"github.com/decentplatforms/matcha/pkg/tree"
+)
+
+// ProblemKind is the kind of a Problem found by Validate.
+type ProblemKind int
+
+const (
+	// The route has the same expression as an earlier route, so it is never matched.
+	Duplicate ProblemKind = iota + 1
+	// Every request the route matches is matched by an earlier route first, so it is never matched.
+	Shadowed
+	// The route has a regex part that can't match any path segment, so it is never matched.
+	Unreachable
+)
+
+func (k ProblemKind) String() string {
+	switch k {
+	case Duplicate:
+		return "duplicate"
+	case Shadowed:
+		return "shadowed"
+	case Unreachable:
+		return "unreachable"
+	default:
+		return fmt.Sprintf("ProblemKind(%d)", int(k))
+	}
+}
+
+// A Problem is a route that a Router can never serve.
+// Problems are errors, so they can be returned by Routers in strict mode.
+type Problem struct {
+	Kind ProblemKind
+	// The route that is never served.
+	Route route.Route
+	// The earlier route that Route duplicates or is shadowed by; nil if Route is Unreachable.
+	By route.Route
+}
+
+func (p Problem) Error() string {
+	switch p.Kind {
+	case Duplicate:
+		return fmt.Sprintf("route %s duplicates route %s", describe(p.Route), describe(p.By))
+	case Shadowed:
+		return fmt.Sprintf("route %s is shadowed by route %s", describe(p.Route), describe(p.By))
+	default:
+		return fmt.Sprintf("route %s has a part that can't match any path segment", describe(p.Route))
+	}
+}
+
+// describe describes a route in a Problem, by its method and expression, or its hash if it has no expression.
+func describe(r route.Route) string {
+	if expr := route.ExprOf(r); expr != "" {
+		return r.Method() + " " + expr
+	}
+	return r.Hash()
+}
+
+// routeHandler is implemented by Routers that can report errors registering routes, like Routers in
+// strict mode.
+type routeHandler interface {
+	handle(r route.Route, h http.Handler) (route.Route, error)
+}
+
+// registerRoute adds a route to a Router, and returns the route the Router stored for it, which is a copy of r on
+// case-insensitive Routers, along with any error registering it.
+func registerRoute(rt Router, r route.Route, h http.Handler) (route.Route, error) {
+	if rh, ok := rt.(routeHandler); ok {
+		return rh.handle(r, h)
+	}
+	rt.HandleRoute(r, h)
+	return r, nil
+}
+
+// handleRoute adds a route to a Router, returning any error registering it.
+func handleRoute(rt Router, r route.Route, h http.Handler) error {
+	_, err := registerRoute(rt, r, h)
+	return err
+}
+
+// shadows checks if a route always matches requests before a later route can.
+// Routes with requirements may not match every request with a matching path, and routes disabled with
+// status 404 are skipped when matching, so neither shadows other routes.
+func (t *table) shadows(id int, r route.Route) bool {
+	return len(t.routes[id].Required()) == 0 && t.disabled[id] != http.StatusNotFound && route.Covers(t.routes[id], r)
+}
+
+// problem finds the problem with the route with leaf ID id, given the leaf IDs of the routes matched
+// before it.
+func (t *table) problem(id int, before []int) (Problem, bool) {
+	r := t.routes[id]
+	for _, p := range r.Parts() {
+		if !route.CanMatch(p) {
+			return Problem{Kind: Unreachable, Route: r}, true
+		}
+	}
+	for _, bid := range before {
+		if !t.shadows(bid, r) {
+			continue
+		}
+		if t.routes[bid].Hash() == r.Hash() {
+			return Problem{Kind: Duplicate, Route: r, By: t.routes[bid]}, true
+		}
+		return Problem{Kind: Shadowed, Route: r, By: t.routes[bid]}, true
+	}
+	return Problem{}, false
+}
+
+// validate finds the problems with every route in the table, in registration order.
+func (t *table) validate() []Problem {
+	found := make(map[int]Problem)
+	methods := make(map[string]bool)
+	for _, r := range t.routes {
+		methods[r.Method()] = true
+	}
+	for method := range methods {
+		order := t.rtree.Order(method)
+		for i, id := range order {
+			if p, ok := t.problem(id, order[:i]); ok {
+				found[id] = p
+			}
+		}
+	}
+	var problems []Problem
+	for _, id := range t.ids() {
+		if p, ok := found[id]; ok {
+			problems = append(problems, p)
+		}
+	}
+	return problems
+}
+
+// add registers a route and its handler on the table, like register.
+// In strict mode, the route is checked once it's registered, since routes aren't always matched in registration
+// order; if it has a problem, it's removed again, and the Problem is returned.
+func (t *table) add(r route.Route, h http.Handler) (int, error) {
+	id := t.register(r, h)
+	if !t.strict {
+		return id, nil
+	}
+	if p, ok := t.check(id); ok {
+		t.unregister(id)
+		return tree.NO_LEAF_ID, p
+	}
+	return id, nil
+}
+
+// check finds the problem with the route with leaf ID id, if there is one.
+func (t *table) check(id int) (Problem, bool) {
+	order := t.rtree.Order(t.routes[id].Method())
+	for i, oid := range order {
+		if oid == id {
+			return t.problem(id, order[:i])
+		}
+	}
+	return Problem{}, false
+}
+
+// Find routes that the Router can never serve.
+//
+// See interface Router.
+func (rt *defaultRouter) Validate() []Problem {
+	t := rt.tbl.Load()
+	return append(t.validate(), t.rejected...)
+}
+
+// Find routes in the group that its Router can never serve.
+//
+// See interface Router.
+func (g *group) Validate() []Problem {
+	var problems []Problem
+	for _, p := range g.parent.Validate() {
+		for _, r := range g.routes {
+			if p.Route == r {
+				problems = append(problems, p)
+				break
+			}
+		}
+	}
+	return problems
+}
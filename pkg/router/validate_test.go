@@ -0,0 +1,148 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/decentplatforms/matcha/pkg/route"
+	"github.com/decentplatforms/matcha/pkg/route/require"
+)
+
+// describeProblems gets the kind and route expression of each problem.
+func describeProblems(ps []Problem) []string {
+	ds := make([]string, 0, len(ps))
+	for _, p := range ps {
+		ds = append(ds, p.Kind.String()+" "+route.ExprOf(p.Route))
+	}
+	return ds
+}
+
+func TestValidate(t *testing.T) {
+	rt := Declare(
+		Default(),
+		// Routes with requirements don't shadow later routes.
+		HandleRoute(route.Declare(http.MethodGet, "/", route.Require(require.Hosts("decentplatforms.com"))), okHandler("root")),
+		HandleFunc(http.MethodGet, "/", okHandler("root")),
+		HandleFunc(http.MethodGet, "/users/[id]", okHandler("id")),
+		HandleFunc(http.MethodGet, "/users/me", okHandler("me")),
+		HandleFunc(http.MethodPost, "/users/me", okHandler("post")),
+		HandleFunc(http.MethodGet, "/files/[name]", okHandler("files")),
+		HandleFunc(http.MethodGet, "/files/[name]", okHandler("files again")),
+		HandleFunc(http.MethodGet, "/[n]{[0-9]+}", okHandler("number")),
+		HandleFunc(http.MethodGet, "/{ab\\x2fc}", okHandler("slash")),
+	)
+	if err := rt.Mount("/api", okHandler("api"), http.MethodGet); err != nil {
+		t.Fatal(err)
+	}
+	rt.HandleFunc(http.MethodGet, "/api/v1/status", okHandler("status"))
+	want := []string{"shadowed /users/me", "duplicate /files/[name]", "unreachable /{ab\\x2fc}", "shadowed /api/v1/status"}
+	if got := describeProblems(rt.Validate()); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	problems := rt.Validate()
+	if by := route.ExprOf(problems[0].By); by != "/users/[id]" {
+		t.Errorf("expected /users/me to be shadowed by /users/[id], got %s", by)
+	}
+	if msg := problems[1].Error(); msg != "route GET /files/[name] duplicates route GET /files/[name]" {
+		t.Errorf("unexpected message %q", msg)
+	}
+	// Problems depend on the order routes are matched in.
+	if err := MostSpecific()(rt); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"duplicate /files/[name]", "unreachable /{ab\\x2fc}"}
+	if got := describeProblems(rt.Validate()); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v in priority mode, got %v", want, got)
+	}
+	// Routes disabled with 404 are skipped, so they don't shadow later routes.
+	if err := rt.Disable(http.MethodGet, "/files/[name]", http.StatusNotFound); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"unreachable /{ab\\x2fc}"}
+	if got := describeProblems(rt.Validate()); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v with disabled route, got %v", want, got)
+	}
+}
+
+func TestValidateGroup(t *testing.T) {
+	rt := Declare(
+		Default(),
+		HandleFunc(http.MethodGet, "/+", okHandler("any")),
+	)
+	var g Router
+	rt.Group("/v1", func(v1 Router) {
+		g = v1
+		v1.HandleFunc(http.MethodGet, "/status", okHandler("status"))
+	})
+	rt.HandleFunc(http.MethodGet, "/other", okHandler("other"))
+	if got := describeProblems(rt.Validate()); len(got) != 2 {
+		t.Errorf("expected 2 problems, got %v", got)
+	}
+	want := []string{"shadowed /v1/status"}
+	if got := describeProblems(g.Validate()); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v in group, got %v", want, got)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	rt := Declare(
+		Default(),
+		Strict(),
+		HandleFunc(http.MethodGet, "/users/[id]", okHandler("id")),
+	)
+	var p Problem
+	if err := rt.HandleFunc(http.MethodGet, "/users/me", okHandler("me")); !errors.As(err, &p) || p.Kind != Shadowed {
+		t.Errorf("expected shadowed problem, got %v", err)
+	}
+	if err := rt.Handle(http.MethodGet, "/users/[id]", okHandler("id")); !errors.As(err, &p) || p.Kind != Duplicate {
+		t.Errorf("expected duplicate problem, got %v", err)
+	}
+	if err := rt.Handle(http.MethodGet, "/{\\x2f}", okHandler("slash")); !errors.As(err, &p) || p.Kind != Unreachable {
+		t.Errorf("expected unreachable problem, got %v", err)
+	}
+	if err := rt.Mount("/api", okHandler("api"), http.MethodGet); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Mount("/api", okHandler("api"), http.MethodGet); !errors.As(err, &p) || p.Kind != Duplicate {
+		t.Errorf("expected mount to fail, got %v", err)
+	}
+	if err := HandleRoute(route.Declare(http.MethodGet, "/users/[name]"), okHandler("name"))(rt); err == nil {
+		t.Error("expected HandleRoute ConfigFunc to fail")
+	}
+	err := rt.Group("/users", func(g Router) {
+		g.HandleFunc(http.MethodGet, "/me", okHandler("me"))
+	})
+	if !errors.As(err, &p) || p.Kind != Shadowed {
+		t.Errorf("expected group to fail, got %v", err)
+	}
+	// Mounts are registered for every method or none.
+	if err := rt.Mount("/api", okHandler("api"), http.MethodPost, http.MethodGet); !errors.As(err, &p) || p.Kind != Duplicate {
+		t.Errorf("expected mount to fail, got %v", err)
+	}
+	// Methods that can't return errors keep the Problem for Validate instead.
+	rt.HandleRoute(route.Declare(http.MethodGet, "/users/me"), okHandler("me"))
+	rt.AddRoute(route.Declare(http.MethodGet, "/users/[id]"), okHandler("id"))
+	if infos := rt.Routes(); len(infos) != 2 {
+		t.Errorf("expected rejected routes not to be registered, got %v", infos)
+	}
+	var kinds []ProblemKind
+	for _, p := range rt.Validate() {
+		kinds = append(kinds, p.Kind)
+	}
+	if !reflect.DeepEqual(kinds, []ProblemKind{Shadowed, Duplicate}) {
+		t.Errorf("expected rejected routes to be reported, got %v", kinds)
+	}
+	if err := rt.HandleFunc(http.MethodGet, "/posts/[id]", okHandler("post")); err != nil {
+		t.Errorf("expected valid route to register, got %v", err)
+	}
+	// In priority mode, routes are checked against the routes matched before them.
+	prt := Declare(Default(), Strict(), MostSpecific(), HandleFunc(http.MethodGet, "/a/[b]", okHandler("any")))
+	if err := prt.HandleFunc(http.MethodGet, "/a/c", okHandler("c")); err != nil {
+		t.Errorf("expected no problem, got %v", err)
+	}
+	if _, err := asDefault(&otherRouter{rt}, "Strict"); err == nil {
+		t.Error("expected Strict to be unsupported by other routers")
+	}
+}
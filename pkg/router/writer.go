@@ -0,0 +1,143 @@
+package router
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// headWriter drops the body of a response to a HEAD request, but keeps its headers.
+// Writing the status is delayed until the handler is finished, so that Content-Length can be set to the
+// length of the dropped body if the handler doesn't set it.
+type headWriter struct {
+	http.ResponseWriter
+	code  int
+	n     int
+	wrote bool
+}
+
+func (hw *headWriter) WriteHeader(code int) {
+	if hw.code == 0 {
+		hw.code = code
+	}
+}
+
+func (hw *headWriter) Write(p []byte) (int, error) {
+	if hw.code == 0 {
+		hw.code = http.StatusOK
+	}
+	hw.n += len(p)
+	return len(p), nil
+}
+
+// Flush writes the status now, instead of once the handler is done, and flushes the underlying
+// http.ResponseWriter if it's an http.Flusher.
+// Content-Length isn't set, since the length of the body isn't known yet.
+func (hw *headWriter) Flush() {
+	hw.writeStatus()
+	if f, ok := hw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hijacks the underlying http.ResponseWriter, if it's an http.Hijacker.
+// Once the connection is hijacked, the status isn't written.
+func (hw *headWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := hw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, buf, err := h.Hijack()
+	if err == nil {
+		hw.wrote = true
+	}
+	return conn, buf, err
+}
+
+// Unwrap gets the underlying http.ResponseWriter, for use with http.ResponseController.
+func (hw *headWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
+
+// writeStatus writes the response status, if it hasn't been written yet.
+func (hw *headWriter) writeStatus() {
+	if hw.wrote {
+		return
+	}
+	hw.wrote = true
+	if hw.code == 0 {
+		hw.code = http.StatusOK
+	}
+	hw.ResponseWriter.WriteHeader(hw.code)
+}
+
+// finish writes the response status once the handler is done.
+func (hw *headWriter) finish() {
+	if h := hw.ResponseWriter.Header(); !hw.wrote && h.Get("Content-Length") == "" && hw.n > 0 {
+		h.Set("Content-Length", strconv.Itoa(hw.n))
+	}
+	hw.writeStatus()
+}
+
+// recoveryWriter tracks whether a response has started, so recovering from a panic doesn't write a second
+// status line.
+type recoveryWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+// WriteHeader writes the status, unless a final status was already written.
+// Informational (1xx) statuses don't start the response.
+func (rw *recoveryWriter) WriteHeader(code int) {
+	if rw.wrote {
+		return
+	}
+	if code >= 200 {
+		rw.wrote = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recoveryWriter) Write(p []byte) (int, error) {
+	rw.wrote = true
+	return rw.ResponseWriter.Write(p)
+}
+
+// Unwrap gets the underlying http.ResponseWriter, for use with http.ResponseController.
+func (rw *recoveryWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Flush flushes the underlying http.ResponseWriter, if it's an http.Flusher. Flushing starts the response.
+func (rw *recoveryWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		rw.wrote = true
+		f.Flush()
+	}
+}
+
+// Hijack hijacks the underlying http.ResponseWriter, if it's an http.Hijacker.
+// Once the connection is hijacked, recovering from a panic doesn't write a status.
+func (rw *recoveryWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, buf, err := h.Hijack()
+	if err == nil {
+		rw.wrote = true
+	}
+	return conn, buf, err
+}
+
+// ReadFrom copies src to the response, with the underlying http.ResponseWriter's ReadFrom if it's an
+// io.ReaderFrom, so responses like files can still be sent with sendfile.
+func (rw *recoveryWriter) ReadFrom(src io.Reader) (int64, error) {
+	rw.wrote = true
+	if rf, ok := rw.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+	return io.Copy(rw.ResponseWriter, src)
+}
@@ -0,0 +1,146 @@
+package tree
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/decentplatforms/matcha/pkg/route"
+)
+
+// childIndex finds the children of a node that can match a token without trying each one.
+// Static children are indexed by the token they match, and case-insensitive static children by their folded
+// token; every other child can match any token, so they're all candidates. Each list is kept in the order the
+// children are matched, so candidates can be merged back into that order.
+type childIndex struct {
+	static  map[string][]*node
+	folded  map[string][]*node
+	dynamic []*node
+}
+
+// isASCII checks if a string only has ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// foldKey gets the key of a token in the index of case-insensitive static children.
+// Static tokens are ASCII, so non-ASCII characters in the token are folded to the ASCII character they match,
+// like the Kelvin sign to k. Returns false if the token has a character that can't match any ASCII character.
+func foldKey(token string) (string, bool) {
+	if isASCII(token) {
+		return strings.ToLower(token), true
+	}
+	var sb strings.Builder
+	sb.Grow(len(token))
+	for _, r := range token {
+		if r >= utf8.RuneSelf {
+			f := unicode.SimpleFold(r)
+			for f != r && f >= utf8.RuneSelf {
+				f = unicode.SimpleFold(f)
+			}
+			if f == r {
+				return "", false
+			}
+			r = f
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String(), true
+}
+
+// insertByPos inserts a node into a list ordered by position.
+func insertByPos(ns []*node, n *node) []*node {
+	i := sort.Search(len(ns), func(i int) bool {
+		return ns[i].pos > n.pos
+	})
+	ns = append(ns, nil)
+	copy(ns[i+1:], ns[i:])
+	ns[i] = n
+	return ns
+}
+
+// removeNode removes a node from a list.
+func removeNode(ns []*node, n *node) []*node {
+	for i, c := range ns {
+		if c == n {
+			return append(ns[:i:i], ns[i+1:]...)
+		}
+	}
+	return ns
+}
+
+// add indexes a child. The child's position must already be set.
+func (idx *childIndex) add(child *node) {
+	token, fold, ok := route.StaticToken(child.p)
+	switch {
+	case !ok || !isASCII(token):
+		idx.dynamic = insertByPos(idx.dynamic, child)
+	case fold:
+		if idx.folded == nil {
+			idx.folded = make(map[string][]*node)
+		}
+		key := strings.ToLower(token)
+		idx.folded[key] = insertByPos(idx.folded[key], child)
+	default:
+		if idx.static == nil {
+			idx.static = make(map[string][]*node)
+		}
+		idx.static[token] = insertByPos(idx.static[token], child)
+	}
+}
+
+// remove removes a child from the index.
+func (idx *childIndex) remove(child *node) {
+	token, fold, ok := route.StaticToken(child.p)
+	switch {
+	case !ok || !isASCII(token):
+		idx.dynamic = removeNode(idx.dynamic, child)
+	case fold:
+		key := strings.ToLower(token)
+		if idx.folded[key] = removeNode(idx.folded[key], child); len(idx.folded[key]) == 0 {
+			delete(idx.folded, key)
+		}
+	default:
+		if idx.static[token] = removeNode(idx.static[token], child); len(idx.static[token]) == 0 {
+			delete(idx.static, token)
+		}
+	}
+}
+
+// candidates gets the children that can match a token, as lists ordered by position.
+// If there is no token, because the path has been matched entirely, only dynamic children are candidates.
+func (idx *childIndex) candidates(token string, ok bool) (static, folded, dynamic []*node) {
+	if ok {
+		static = idx.static[token]
+		if len(idx.folded) > 0 {
+			if key, ok := foldKey(token); ok {
+				folded = idx.folded[key]
+			}
+		}
+	}
+	return static, folded, idx.dynamic
+}
+
+// first pops the node with the lowest position from the front of three lists ordered by position.
+// Returns nil if the lists are empty.
+func first(a, b, c *[]*node) *node {
+	low := a
+	if len(*low) == 0 || (len(*b) > 0 && (*b)[0].pos < (*low)[0].pos) {
+		low = b
+	}
+	if len(*low) == 0 || (len(*c) > 0 && (*c)[0].pos < (*low)[0].pos) {
+		low = c
+	}
+	if len(*low) == 0 {
+		return nil
+	}
+	n := (*low)[0]
+	*low = (*low)[1:]
+	return n
+}
@@ -4,77 +4,302 @@
 package tree
 
 import (
+	"context"
 	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/decentplatforms/matcha/pkg/path"
+	"github.com/decentplatforms/matcha/pkg/rctx"
 	"github.com/decentplatforms/matcha/pkg/route"
 	"github.com/decentplatforms/matcha/pkg/route/require"
 )
 
 const NO_LEAF_ID = int(0)
 
+// Nodes match one or more parts of a route. A run of static parts with no other routes branching off between them
+// shares one node, so the run is matched with a single comparison and only takes one node in memory; the node's
+// edge is the path the run matches. Any other part is a branch point, and gets a node of its own.
 type node struct {
 	p             route.Part
+	parts         []route.Part
+	edge          string
+	fold          bool
+	rank          route.PartKind
 	children      []*node
+	index         childIndex
+	pos           int
 	leaf_id       int
 	leaf_required []require.Required
+	disabled      bool
 }
 
 func (n *node) isLeaf() bool {
 	return n.leaf_id != NO_LEAF_ID
 }
 
-func createNode(p route.Part) *node {
-	return &node{
-		p:        p,
+// createNode creates a node that matches parts ps, which share an edge if there are several.
+func createNode(ps []route.Part, edge string) *node {
+	n := &node{
 		children: make([]*node, 0),
 	}
+	if len(ps) > 0 {
+		n.setParts(ps, edge)
+	}
+	return n
+}
+
+// createRun creates a node for the longest run of static parts at the start of ps, or for the first part if it
+// isn't static. Returns the node and the number of parts it matches.
+func createRun(ps []route.Part) (*node, int) {
+	k := runLength(ps)
+	if k < 2 {
+		return createNode(ps[:1], ""), 1
+	}
+	tokens := make([]string, k)
+	for i, p := range ps[:k] {
+		tokens[i], _, _ = route.StaticToken(p)
+	}
+	return createNode(ps[:k], strings.Join(tokens, "")), k
+}
+
+// runLength gets the number of parts at the start of ps that can share a node: static parts that fold the same way.
+// Parts that only match "/" end a run, since they match trailing slashes.
+func runLength(ps []route.Part) int {
+	_, fold, ok := route.StaticToken(ps[0])
+	for i, p := range ps {
+		token, f, isStatic := route.StaticToken(p)
+		if !ok || !isStatic || f != fold || token == "/" {
+			return i
+		}
+	}
+	return len(ps)
+}
+
+// setParts sets the parts the node matches, and the edge they match if there are several.
+func (n *node) setParts(ps []route.Part, edge string) {
+	n.p = ps[0]
+	n.parts = ps
+	n.rank = route.KindOf(ps[0])
+	n.edge = ""
+	n.fold = false
+	if len(ps) > 1 {
+		n.edge = edge
+		_, n.fold, _ = route.StaticToken(ps[0])
+	}
+}
+
+// common gets the number of parts at the start of ps that the node matches.
+func (n *node) common(ps []route.Part) int {
+	i := 0
+	for i < len(n.parts) && i < len(ps) && n.parts[i].Eq(ps[i]) {
+		i++
+	}
+	return i
+}
+
+// split splits the node's run after its first i parts. The rest of the run moves to a new child, which takes the
+// node's children.
+func (n *node) split(i int) {
+	j := 0
+	for _, p := range n.parts[:i] {
+		token, _, _ := route.StaticToken(p)
+		j += len(token)
+	}
+	child := &node{
+		children: n.children,
+		index:    n.index,
+	}
+	child.setParts(n.parts[i:], n.edge[j:])
+	n.setParts(n.parts[:i], n.edge[:j])
+	n.children = []*node{child}
+	n.index = childIndex{}
+}
+
+// Nodes with many children index them, so matching only tries the children that can match the next token; pos is
+// the index of a node in its parent's children, which keeps candidates in the order the children are matched.
+
+// insert adds a child to the node.
+// In priority mode, children are kept sorted by rank, and children with the same rank keep the order they were
+// added in; otherwise, children are always added last.
+func (n *node) insert(child *node, priority bool) {
+	i := len(n.children)
+	if priority {
+		i = sort.Search(len(n.children), func(i int) bool {
+			return n.children[i].rank > child.rank
+		})
+	}
+	n.children = append(n.children, nil)
+	copy(n.children[i+1:], n.children[i:])
+	n.children[i] = child
+	n.renumber(i)
+	switch {
+	case len(n.children) > indexedChildren:
+		n.index.add(child)
+	case len(n.children) == indexedChildren:
+		n.reindex()
+	}
+}
+
+// renumber updates the positions of the node's children, starting from the child at index i.
+func (n *node) renumber(i int) {
+	for ; i < len(n.children); i++ {
+		n.children[i].pos = i
+	}
+}
+
+// reindex rebuilds the index of the node's children, if it has enough to need one.
+func (n *node) reindex() {
+	n.renumber(0)
+	n.index = childIndex{}
+	if len(n.children) < indexedChildren {
+		return
+	}
+	for _, child := range n.children {
+		n.index.add(child)
+	}
+}
+
+// prioritize stably sorts the children of every node in the subtree by rank.
+func (n *node) prioritize() {
+	sort.SliceStable(n.children, func(i, j int) bool {
+		return n.children[i].rank < n.children[j].rank
+	})
+	n.reindex()
+	for _, child := range n.children {
+		child.prioritize()
+	}
 }
 
+// resolveLeafForRequest gets the leaf ID of the node if it's a leaf that the request meets the requirements of.
+// A nil request meets every requirement.
 func (n *node) resolveLeafForRequest(req *http.Request) int {
-	if n.leaf_id == NO_LEAF_ID {
+	if n.leaf_id == NO_LEAF_ID || n.disabled {
 		return NO_LEAF_ID
 	}
-	if !require.Execute(req, n.leaf_required) {
+	if req != nil && !require.Execute(req, n.leaf_required) {
 		return NO_LEAF_ID
 	}
 	return n.leaf_id
 }
 
+// clone copies the subtree with this node as the root.
+// Parts and requirements are shared, since they aren't modified once added.
+func (n *node) clone() *node {
+	c := &node{
+		p:             n.p,
+		parts:         n.parts,
+		edge:          n.edge,
+		fold:          n.fold,
+		rank:          n.rank,
+		children:      make([]*node, len(n.children)),
+		leaf_id:       n.leaf_id,
+		leaf_required: n.leaf_required,
+		disabled:      n.disabled,
+	}
+	for i, child := range n.children {
+		c.children[i] = child.clone()
+	}
+	c.reindex()
+	return c
+}
+
 // Propagate a set of parts through the tree, with this node as the root.
 // If there are no parts left to propagate, the node will instead be set to leaf leaf_id.
-func (n *node) propagate(r route.Route, ps []route.Part, leaf_id int) {
+// The last part of a route always gets a node of its own, so leaves never share a node with the parts before them.
+func (n *node) propagate(r route.Route, ps []route.Part, leaf_id int, priority bool) {
 	if len(ps) == 0 {
 		n.leaf_id = leaf_id
 		n.leaf_required = r.Required()
 		return
 	}
-	next := ps[0]
 	if !n.isLeaf() && len(ps)-1 != 0 {
 		for _, child := range n.children {
-			if child.p.Eq(next) && !child.isLeaf() {
-				child.propagate(r, ps[1:], leaf_id)
+			if child.isLeaf() {
+				continue
+			}
+			if i := child.common(ps[:len(ps)-1]); i > 0 {
+				if i < len(child.parts) {
+					child.split(i)
+				}
+				child.propagate(r, ps[i:], leaf_id, priority)
 				return
 			}
 		}
 	}
-	child := createNode(next)
-	child.propagate(r, ps[1:], leaf_id)
-	child.propagate(r, ps[1:], leaf_id)
-	n.children = append(n.children, child)
+	run := ps
+	if len(ps) > 1 {
+		run = ps[:len(ps)-1]
+	}
+	child, i := createRun(run)
+	child.propagate(r, ps[i:], leaf_id, priority)
+	n.insert(child, priority)
+}
+
+// find gets the leaf with leaf_id in the subtree with this node as the root, or nil if there isn't one.
+func (n *node) find(leaf_id int) *node {
+	if n.leaf_id == leaf_id {
+		return n
+	}
+	for _, child := range n.children {
+		if leaf := child.find(leaf_id); leaf != nil {
+			return leaf
+		}
+	}
+	return nil
+}
+
+// leaves appends the leaf IDs in the subtree with this node as the root, in the order they're matched.
+func (n *node) leaves(leaf_ids []int) []int {
+	if n.isLeaf() {
+		leaf_ids = append(leaf_ids, n.leaf_id)
+	}
+	for _, child := range n.children {
+		leaf_ids = child.leaves(leaf_ids)
+	}
+	return leaf_ids
+}
+
+// remove removes the leaf with leaf_id from the subtree with this node as the root, and prunes nodes that
+// are left with no children and no leaf. Returns true if the leaf was found.
+func (n *node) remove(leaf_id int) bool {
+	for i, child := range n.children {
+		if child.leaf_id == leaf_id {
+			child.leaf_id = NO_LEAF_ID
+			child.leaf_required = nil
+			child.disabled = false
+		} else if !child.remove(leaf_id) {
+			continue
+		}
+		if !child.isLeaf() && len(child.children) == 0 {
+			n.children = append(n.children[:i:i], n.children[i+1:]...)
+			n.renumber(i)
+			if len(n.children) >= indexedChildren {
+				n.index.remove(child)
+			} else {
+				n.index = childIndex{}
+			}
+		}
+		return true
+	}
+	return false
 }
 
 // match traverses a subtree of nodes to find the first matching route.
-func (n *node) match(req *http.Request, expr string, last int) int {
-	// If we've reached the end of the expression, return the leaf_id of the current node.
-	// This encapsulates several edge cases where it's difficult to know if the routine should return early or not,
-	// like with partial leaves.
+// If ctx isn't nil, parts set their params on it as they match, and params set in subtrees that don't match
+// are removed, so once a route matches, ctx has the params of that route.
+func (n *node) match(req *http.Request, ctx context.Context, expr string, last int) int {
+	// If we've reached the end of the expression, return the leaf_id of the current node if it's partial, since
+	// partial leaves match their roots. Any other node needs a token to match.
+	// This encapsulates several edge cases where it's difficult to know if the routine should return early or not.
 	if last == -1 {
-		return n.resolveLeafForRequest(req)
+		if route.IsPartialEndPart(n.p) {
+			return n.resolveLeafForRequest(req)
+		}
+		return NO_LEAF_ID
 	}
-	// Get the next token from the path and match it against the route.Part of the current node.
-	token, next := path.Next(expr, last)
-	ok := n.p.Match(nil, token)
+	// Match the next tokens from the path against the parts of the current node.
+	next, ok := n.matchParts(ctx, expr, last)
 	if !ok {
 		// If the part doesn't match, return NO_LEAF_ID.
 		return NO_LEAF_ID
@@ -82,7 +307,7 @@ func (n *node) match(req *http.Request, expr string, last int) int {
 		// If the part matches...
 		if route.IsPartialEndPart(n.p) {
 			// ...and the leaf is partial, return the result of recursively matching until termination.
-			return n.match(req, expr, next)
+			return n.match(req, ctx, expr, next)
 		} else if next == -1 {
 			// ...and the route has been exhausted, return the id of the leaf as a successful match.
 			return n.resolveLeafForRequest(req)
@@ -92,56 +317,260 @@ func (n *node) match(req *http.Request, expr string, last int) int {
 		}
 	}
 	// Iterate through the children of this node.
-	for _, child := range n.children {
-		match_leaf_id := child.match(req, expr, next)
-		if match_leaf_id != 0 {
-			// If a child matches the entire remaining route, return its leaf_id.
+	return n.matchChildren(req, ctx, expr, next)
+}
+
+// matchParts matches the parts of the node against the path, starting at position last, and returns the
+// position to match the node's children from.
+// Runs of static parts are compared to the path all at once; if the path doesn't have the same segments, like if it
+// has consecutive slashes, the parts are matched token by token instead.
+func (n *node) matchParts(ctx context.Context, expr string, last int) (int, bool) {
+	if n.edge != "" {
+		if end := last + len(n.edge); end <= len(expr) && (end == len(expr) || expr[end] == '/') {
+			if s := expr[last:end]; s == n.edge || (n.fold && strings.EqualFold(s, n.edge)) {
+				if end == len(expr) {
+					return -1, true
+				}
+				return end, true
+			}
+		}
+	}
+	next := last
+	for _, p := range n.parts {
+		if next == -1 {
+			return -1, false
+		}
+		var token string
+		token, next = path.Next(expr, next)
+		if !p.Match(ctx, token) {
+			return next, false
+		}
+	}
+	return next, true
+}
+
+// indexedChildren is the number of children a node needs before the index is used to match them.
+// Trying a few children in turn is faster than looking them up.
+const indexedChildren = 8
+
+// matchChildren matches a request against the children of the node in turn, and returns the leaf_id of the first
+// that matches the entire remaining route. For nodes with many children, only the static children that match the
+// next token are tried, along with every child that isn't static, in the same order as the children.
+// The params set or replaced by children that don't match are restored before the next is tried.
+func (n *node) matchChildren(req *http.Request, ctx context.Context, expr string, last int) int {
+	var mark rctx.ParamMark
+	if ctx != nil {
+		mark = rctx.MarkParams(ctx)
+	}
+	if len(n.children) < indexedChildren {
+		for _, child := range n.children {
+			if match_leaf_id := child.match(req, ctx, expr, last); match_leaf_id != NO_LEAF_ID {
+				return match_leaf_id
+			}
+			if ctx != nil {
+				rctx.ResetParams(ctx, mark)
+			}
+		}
+		return NO_LEAF_ID
+	}
+	var token string
+	if last != -1 {
+		token, _ = path.Next(expr, last)
+	}
+	static, folded, dynamic := n.index.candidates(token, last != -1)
+	for child := first(&static, &folded, &dynamic); child != nil; child = first(&static, &folded, &dynamic) {
+		if match_leaf_id := child.match(req, ctx, expr, last); match_leaf_id != NO_LEAF_ID {
 			return match_leaf_id
 		}
+		if ctx != nil {
+			rctx.ResetParams(ctx, mark)
+		}
 	}
-	// If we reach this point, the entire subtree from this node has been traversed with no match.
+	// If we reach this point, every subtree has been traversed with no match.
 	return NO_LEAF_ID
 }
 
 type RouteTree struct {
 	methodRoot map[string]*node
+	fallback   map[string]string
 	nextId     int
+	priority   bool
 }
 
 // Create a new RouteTree.
 func New() *RouteTree {
 	return &RouteTree{
 		methodRoot: make(map[string]*node),
+		fallback:   make(map[string]string),
 		nextId:     0,
 	}
 }
 
+// SetFallback makes requests with method fall back to the routes for method to if none of the
+// routes for method match.
+// For example, SetFallback(http.MethodHead, http.MethodGet) serves HEAD requests with GET routes.
+func (rtree *RouteTree) SetFallback(method, to string) {
+	rtree.fallback[method] = to
+}
+
+// Clone copies the tree, so routes can be added to the copy without affecting the original.
+func (rtree *RouteTree) Clone() *RouteTree {
+	c := &RouteTree{
+		methodRoot: make(map[string]*node, len(rtree.methodRoot)),
+		fallback:   make(map[string]string, len(rtree.fallback)),
+		nextId:     rtree.nextId,
+		priority:   rtree.priority,
+	}
+	for method, root := range rtree.methodRoot {
+		c.methodRoot[method] = root.clone()
+	}
+	for method, to := range rtree.fallback {
+		c.fallback[method] = to
+	}
+	return c
+}
+
+// SetPriority enables or disables priority mode.
+// In priority mode, routes are matched by specificity instead of registration order: at each path segment,
+// static parts are tried before regex parts, regex parts before wildcards, and wildcards before partials.
+// Routes that are equally specific are still matched in registration order.
+//
+// Enabling priority mode reorders routes already in the tree. Disabling it only affects routes added afterward.
+func (rtree *RouteTree) SetPriority(enabled bool) {
+	if enabled && !rtree.priority {
+		for _, root := range rtree.methodRoot {
+			root.prioritize()
+		}
+	}
+	rtree.priority = enabled
+}
+
 // Add a route to the tree.
 // Returns the leaf ID of the added route.
 func (rtree *RouteTree) Add(r route.Route) int {
 	root, ok := rtree.methodRoot[r.Method()]
 	if !ok || root == nil {
-		root = createNode(nil)
+		root = createNode(nil, "")
 		rtree.methodRoot[r.Method()] = root
 	}
 	rtree.nextId++
-	root.propagate(r, r.Parts(), rtree.nextId)
+	root.propagate(r, r.Parts(), rtree.nextId, rtree.priority)
 	return rtree.nextId
 }
 
+// Remove the route with leaf ID leaf_id from the tree.
+// Nodes that no longer lead to any route are pruned, and the remaining routes keep their leaf IDs and order.
+// Returns false if no route has the leaf ID.
+func (rtree *RouteTree) Remove(leaf_id int) bool {
+	if leaf_id == NO_LEAF_ID {
+		return false
+	}
+	for method, root := range rtree.methodRoot {
+		if root.remove(leaf_id) {
+			if len(root.children) == 0 {
+				delete(rtree.methodRoot, method)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// SetEnabled enables or disables the route with leaf ID leaf_id.
+// Disabled routes stay in the tree, but never match; matching continues as if they weren't there.
+// Returns false if no route has the leaf ID.
+func (rtree *RouteTree) SetEnabled(leaf_id int, enabled bool) bool {
+	if leaf_id == NO_LEAF_ID {
+		return false
+	}
+	for _, root := range rtree.methodRoot {
+		if leaf := root.find(leaf_id); leaf != nil {
+			leaf.disabled = !enabled
+			return true
+		}
+	}
+	return false
+}
+
+// Order gets the leaf IDs of the routes for method in the order they're matched.
+// A request is served by the first route in the order that matches it.
+func (rtree *RouteTree) Order(method string) []int {
+	root, ok := rtree.methodRoot[method]
+	if !ok {
+		return nil
+	}
+	return root.leaves(nil)
+}
+
 // Match a request to the tree.
 // Returns the leaf ID of the matched route, or NO_LEAF_ID if no match is found.
 func (rtree *RouteTree) Match(req *http.Request) int {
-	root, ok := rtree.methodRoot[req.Method]
-	if !ok || root == nil {
-		return 0
+	return rtree.MatchMethod(req, req.Method)
+}
+
+// MatchMethod matches a request to the tree as if it had been sent with the given method.
+// If no route for method matches and method has a fallback, the routes for the fallback are matched instead.
+// Returns the leaf ID of the matched route, or NO_LEAF_ID if no match is found.
+func (rtree *RouteTree) MatchMethod(req *http.Request, method string) int {
+	return rtree.match(req, nil, method, req.URL.Path)
+}
+
+// MatchPath matches a request path to the routes for method, as if the request met the requirements of every route.
+// If a route without requirements matches a request, and MatchPath matches the request's path to the same route,
+// no route with requirements could have matched first, so every request with that method and path matches it.
+// Returns the leaf ID of the matched route, or NO_LEAF_ID if no match is found.
+func (rtree *RouteTree) MatchPath(method, rpath string) int {
+	return rtree.match(nil, nil, method, rpath)
+}
+
+// Bind matches a request to the tree like Match, and sets the params of the matched route on ctx as the tree is
+// traversed, so the route doesn't need to match the request again to get them.
+// Params set by routes that don't match are removed, but ctx should have enough capacity for the params of any
+// route in the tree. If no route matches, ctx has no params set by the tree.
+func (rtree *RouteTree) Bind(req *http.Request, ctx *rctx.Context) int {
+	if ctx == nil {
+		return rtree.Match(req)
 	}
-	expr := req.URL.Path
-	for _, r := range root.children {
-		match_leaf_id := r.match(req, expr, 0)
-		if match_leaf_id != NO_LEAF_ID {
-			return match_leaf_id
+	leaf_id := rtree.match(req, ctx, req.Method, req.URL.Path)
+	rctx.ClearParamMarks(ctx)
+	return leaf_id
+}
+
+// match matches a request path to the routes for method, or its fallback, setting params on ctx if it isn't nil.
+// If req is nil, requirements aren't checked.
+func (rtree *RouteTree) match(req *http.Request, ctx context.Context, method, expr string) int {
+	if leaf_id := matchRoot(req, ctx, rtree.methodRoot[method], expr); leaf_id != NO_LEAF_ID {
+		return leaf_id
+	}
+	if fallback, ok := rtree.fallback[method]; ok {
+		return matchRoot(req, ctx, rtree.methodRoot[fallback], expr)
+	}
+	return NO_LEAF_ID
+}
+
+// matchRoot matches a request path to the routes under a method root.
+func matchRoot(req *http.Request, ctx context.Context, root *node, expr string) int {
+	if root == nil {
+		return NO_LEAF_ID
+	}
+	return root.matchChildren(req, ctx, expr, 0)
+}
+
+// Allowed gets the methods that have a route matching the request path, in sorted order.
+// The request's own method is included if it matches, and methods with fallbacks are included
+// if their fallback matches.
+func (rtree *RouteTree) Allowed(req *http.Request) []string {
+	var allowed []string
+	for method := range rtree.methodRoot {
+		if rtree.MatchMethod(req, method) != NO_LEAF_ID {
+			allowed = append(allowed, method)
+		}
+	}
+	for method := range rtree.fallback {
+		if _, ok := rtree.methodRoot[method]; !ok && rtree.MatchMethod(req, method) != NO_LEAF_ID {
+			allowed = append(allowed, method)
 		}
 	}
-	return 0
+	sort.Strings(allowed)
+	return allowed
 }
@@ -1,8 +1,10 @@
 package tree
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 
 	"github.com/decentplatforms/matcha/pkg/rctx"
@@ -53,6 +55,42 @@ func TestTree(t *testing.T) {
 	}
 }
 
+func TestShortRequest(t *testing.T) {
+	rtree := New()
+	rtree.Add(route.Declare(http.MethodGet, "/orgs/[org]"))
+	rtree.Add(route.Declare(http.MethodGet, "/orgs/[org]/+"))
+	rtree.Add(route.Declare(http.MethodGet, "/orgs"))
+	// Only partial leaves match their roots.
+	if leaf_id := rtree.Match(httptest.NewRequest(http.MethodGet, "/orgs", nil)); leaf_id != 3 {
+		t.Errorf("expected leaf_id 3, got %d", leaf_id)
+	}
+	if leaf_id := rtree.Match(httptest.NewRequest(http.MethodGet, "/orgs/decent", nil)); leaf_id != 1 {
+		t.Errorf("expected leaf_id 1, got %d", leaf_id)
+	}
+}
+
+// Requests that run out of tokens before a route's last part don't match it.
+func TestShortRequestLeaf(t *testing.T) {
+	for _, expr := range []string{"/a/b", "/a/[b]", "/a/{[a-z]+}", "/a/b/c"} {
+		rtree := New()
+		rtree.Add(route.Declare(http.MethodGet, expr))
+		if leaf_id := rtree.Match(httptest.NewRequest(http.MethodGet, "/a", nil)); leaf_id != NO_LEAF_ID {
+			t.Errorf("%s: expected /a not to match, got leaf_id %d", expr, leaf_id)
+		}
+		if leaf_id := rtree.Bind(httptest.NewRequest(http.MethodGet, "/a", nil), rctx.Acquire(1)); leaf_id != NO_LEAF_ID {
+			t.Errorf("%s: expected /a not to bind, got leaf_id %d", expr, leaf_id)
+		}
+	}
+	rtree := New()
+	rtree.Add(route.Declare(http.MethodGet, "/a/b/+"))
+	if leaf_id := rtree.Match(httptest.NewRequest(http.MethodGet, "/a/b", nil)); leaf_id != 1 {
+		t.Errorf("expected partial route to match its root, got leaf_id %d", leaf_id)
+	}
+	if leaf_id := rtree.Match(httptest.NewRequest(http.MethodGet, "/a", nil)); leaf_id != NO_LEAF_ID {
+		t.Errorf("expected partial route not to match /a, got leaf_id %d", leaf_id)
+	}
+}
+
 func TestDuplicate(t *testing.T) {
 	rtree := New()
 	a := rtree.Add(route.Declare(http.MethodGet, "/duplicate/route"))
@@ -88,3 +126,358 @@ func TestRequire(t *testing.T) {
 		t.Errorf("expected leaf_id 2, got %d", c)
 	}
 }
+
+func TestAllowed(t *testing.T) {
+	rtree := New()
+	rtree.Add(route.Declare(http.MethodGet, "/resource/[id]"))
+	rtree.Add(route.Declare(http.MethodPut, "/resource/[id]"))
+	rtree.Add(route.Declare(http.MethodDelete, "/resource/[id]{[0-9]+}"))
+	rtree.Add(route.Declare(http.MethodPost, "/other"))
+	allowed := rtree.Allowed(httptest.NewRequest(http.MethodPatch, "/resource/abc", nil))
+	if len(allowed) != 2 || allowed[0] != http.MethodGet || allowed[1] != http.MethodPut {
+		t.Errorf("expected [GET PUT], got %v", allowed)
+	}
+	allowed = rtree.Allowed(httptest.NewRequest(http.MethodPatch, "/resource/123", nil))
+	if len(allowed) != 3 || allowed[0] != http.MethodDelete {
+		t.Errorf("expected [DELETE GET PUT], got %v", allowed)
+	}
+	if allowed = rtree.Allowed(httptest.NewRequest(http.MethodGet, "/missing", nil)); len(allowed) != 0 {
+		t.Errorf("expected no methods, got %v", allowed)
+	}
+	if id := rtree.MatchMethod(httptest.NewRequest(http.MethodGet, "/other", nil), http.MethodPost); id != 4 {
+		t.Errorf("expected leaf_id 4, got %d", id)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	rtree := New()
+	rtree.Add(route.Declare(http.MethodGet, "/resource"))
+	rtree.Add(route.Declare(http.MethodGet, "/explicit"))
+	rtree.Add(route.Declare(http.MethodHead, "/explicit"))
+	if id := rtree.Match(httptest.NewRequest(http.MethodHead, "/resource", nil)); id != NO_LEAF_ID {
+		t.Errorf("expected no match without fallback, got %d", id)
+	}
+	rtree.SetFallback(http.MethodHead, http.MethodGet)
+	if id := rtree.Match(httptest.NewRequest(http.MethodHead, "/resource", nil)); id != 1 {
+		t.Errorf("expected leaf_id 1, got %d", id)
+	}
+	if id := rtree.Match(httptest.NewRequest(http.MethodHead, "/explicit", nil)); id != 3 {
+		t.Errorf("expected leaf_id 3, got %d", id)
+	}
+	allowed := rtree.Allowed(httptest.NewRequest(http.MethodPost, "/resource", nil))
+	if len(allowed) != 2 || allowed[0] != http.MethodGet || allowed[1] != http.MethodHead {
+		t.Errorf("expected [GET HEAD], got %v", allowed)
+	}
+}
+
+func TestClone(t *testing.T) {
+	rtree := New()
+	rtree.Add(route.Declare(http.MethodGet, "/a/b"))
+	c := rtree.Clone()
+	if id := c.Add(route.Declare(http.MethodGet, "/a/c")); id != 2 {
+		t.Errorf("expected leaf_id 2, got %d", id)
+	}
+	c.SetFallback(http.MethodHead, http.MethodGet)
+	if leaf_id := rtree.Match(httptest.NewRequest(http.MethodGet, "/a/c", nil)); leaf_id != NO_LEAF_ID {
+		t.Errorf("expected original tree to be unchanged, got %d", leaf_id)
+	}
+	if leaf_id := rtree.Match(httptest.NewRequest(http.MethodHead, "/a/b", nil)); leaf_id != NO_LEAF_ID {
+		t.Errorf("expected original tree to have no fallback, got %d", leaf_id)
+	}
+	if leaf_id := c.Match(httptest.NewRequest(http.MethodGet, "/a/c", nil)); leaf_id != 2 {
+		t.Errorf("expected leaf_id 2, got %d", leaf_id)
+	}
+	if leaf_id := c.Match(httptest.NewRequest(http.MethodHead, "/a/b", nil)); leaf_id != 1 {
+		t.Errorf("expected leaf_id 1, got %d", leaf_id)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	rtree := New()
+	a := rtree.Add(route.Declare(http.MethodGet, "/a/[p]"))
+	b := rtree.Add(route.Declare(http.MethodGet, "/a/b"))
+	c := rtree.Add(route.Declare(http.MethodGet, "/a/[q]/c"))
+	d := rtree.Add(route.Declare(http.MethodPost, "/a/b"))
+	if !rtree.Remove(a) {
+		t.Fatal("expected route to be removed")
+	}
+	if rtree.Remove(a) {
+		t.Error("expected route to already be removed")
+	}
+	// The next route in registration order matches instead.
+	if leaf_id := rtree.Match(httptest.NewRequest(http.MethodGet, "/a/b", nil)); leaf_id != b {
+		t.Errorf("expected leaf_id %d, got %d", b, leaf_id)
+	}
+	if leaf_id := rtree.Match(httptest.NewRequest(http.MethodGet, "/a/x", nil)); leaf_id != NO_LEAF_ID {
+		t.Errorf("expected no match, got %d", leaf_id)
+	}
+	rtree.Remove(c)
+	if n := len(rtree.methodRoot[http.MethodGet].children[0].children); n != 1 {
+		t.Errorf("expected empty nodes to be pruned, got %d children", n)
+	}
+	rtree.Remove(d)
+	if _, ok := rtree.methodRoot[http.MethodPost]; ok {
+		t.Error("expected empty method root to be pruned")
+	}
+	if id := rtree.Add(route.Declare(http.MethodGet, "/a/[p]")); id != d+1 {
+		t.Errorf("expected leaf IDs to not be reused, got %d", id)
+	}
+}
+
+func TestSetEnabled(t *testing.T) {
+	rtree := New()
+	a := rtree.Add(route.Declare(http.MethodGet, "/a/[p]"))
+	b := rtree.Add(route.Declare(http.MethodGet, "/a/b"))
+	rtree.SetEnabled(a, false)
+	if leaf_id := rtree.Match(httptest.NewRequest(http.MethodGet, "/a/b", nil)); leaf_id != b {
+		t.Errorf("expected leaf_id %d, got %d", b, leaf_id)
+	}
+	if allowed := rtree.Allowed(httptest.NewRequest(http.MethodPost, "/a/x", nil)); len(allowed) != 0 {
+		t.Errorf("expected disabled route to not be allowed, got %v", allowed)
+	}
+	rtree.SetEnabled(a, true)
+	if leaf_id := rtree.Match(httptest.NewRequest(http.MethodGet, "/a/b", nil)); leaf_id != a {
+		t.Errorf("expected leaf_id %d, got %d", a, leaf_id)
+	}
+	if rtree.SetEnabled(NO_LEAF_ID, true) || rtree.SetEnabled(10, true) {
+		t.Error("expected missing routes to not be found")
+	}
+}
+
+func TestPriority(t *testing.T) {
+	add := func(rtree *RouteTree) {
+		rtree.Add(route.Declare(http.MethodGet, "/files/+"))            // 1
+		rtree.Add(route.Declare(http.MethodGet, "/files/[name]"))       // 2
+		rtree.Add(route.Declare(http.MethodGet, "/files/[id]{[0-9]+}")) // 3
+		rtree.Add(route.Declare(http.MethodGet, "/files/latest"))       // 4
+		rtree.Add(route.Declare(http.MethodGet, "/[any]/latest"))       // 5
+		rtree.Add(route.Declare(http.MethodGet, "/files/[a]/x"))        // 6
+		rtree.Add(route.Declare(http.MethodGet, "/files/[b]/x"))        // 7
+	}
+	tests := []struct {
+		path     string
+		ordered  int
+		priority int
+	}{
+		{"/files/latest", 1, 4},
+		{"/files/12", 1, 3},
+		{"/files/name", 1, 2},
+		{"/files/a/b", 1, 1},
+		{"/files/a/x", 1, 6},
+		{"/other/latest", 5, 5},
+	}
+	ordered := New()
+	add(ordered)
+	// Priority mode set before and after adding routes gives the same tree.
+	before := New()
+	before.SetPriority(true)
+	add(before)
+	after := New()
+	add(after)
+	after.SetPriority(true)
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if leaf_id := ordered.Match(req); leaf_id != test.ordered {
+			t.Errorf("%s: expected leaf_id %d in registration order, got %d", test.path, test.ordered, leaf_id)
+		}
+		if leaf_id := before.Match(req); leaf_id != test.priority {
+			t.Errorf("%s: expected leaf_id %d in priority mode, got %d", test.path, test.priority, leaf_id)
+		}
+		if leaf_id := after.Match(req); leaf_id != test.priority {
+			t.Errorf("%s: expected leaf_id %d after enabling priority mode, got %d", test.path, test.priority, leaf_id)
+		}
+	}
+	if order := ordered.Order(http.MethodGet); !reflect.DeepEqual(order, []int{1, 2, 3, 4, 6, 7, 5}) {
+		t.Errorf("expected registration order [1 2 3 4 6 7 5], got %v", order)
+	}
+	if order := before.Order(http.MethodGet); !reflect.DeepEqual(order, []int{4, 3, 2, 6, 7, 1, 5}) {
+		t.Errorf("expected priority order [4 3 2 6 7 1 5], got %v", order)
+	}
+	if order := ordered.Order(http.MethodPost); order != nil {
+		t.Errorf("expected no order for method without routes, got %v", order)
+	}
+	if c := before.Clone(); !c.priority {
+		t.Error("expected clone to keep priority mode")
+	}
+}
+
+func TestBind(t *testing.T) {
+	rtree := New()
+	rtree.Add(route.Declare(http.MethodGet, "/orgs/[org]/[team]/members"))
+	rtree.Add(route.Declare(http.MethodGet, "/orgs/[org]/repos/[repo]{[a-z]+}"))
+	rtree.Add(route.Declare(http.MethodGet, "/files/[path]+"))
+	rtree.Add(route.Declare(http.MethodGet, "/[p]/[p]/x"))
+	rtree.Add(route.Declare(http.MethodGet, "/[p]/[q]/y"))
+	rtree.SetFallback(http.MethodHead, http.MethodGet)
+	tests := []struct {
+		method  string
+		path    string
+		leaf_id int
+		params  map[string]string
+	}{
+		{http.MethodGet, "/orgs/decent/admins/members", 1, map[string]string{"org": "decent", "team": "admins"}},
+		// The first route sets team, then fails on its last part; only the second route's params are kept.
+		{http.MethodGet, "/orgs/decent/repos/matcha", 2, map[string]string{"org": "decent", "repo": "matcha", "team": ""}},
+		{http.MethodGet, "/orgs/decent/repos/M4", NO_LEAF_ID, nil},
+		{http.MethodHead, "/files/a/b.txt", 3, map[string]string{"path": "/a/b.txt"}},
+		{http.MethodGet, "/other", NO_LEAF_ID, nil},
+		// The first route replaces p, then fails on its last part; p gets its earlier value back.
+		{http.MethodGet, "/a/b/y", 5, map[string]string{"p": "a", "q": "b"}},
+		{http.MethodGet, "/a/b/x", 4, map[string]string{"p": "b"}},
+	}
+	for _, test := range tests {
+		ctx := rctx.Acquire(rctx.DefaultMaxParams)
+		req := httptest.NewRequest(test.method, test.path, nil)
+		if leaf_id := rtree.Bind(req, ctx); leaf_id != test.leaf_id {
+			t.Errorf("%s %s: expected leaf %d, got %d", test.method, test.path, test.leaf_id, leaf_id)
+		}
+		if test.params == nil && rctx.ParamCount(ctx) != 0 {
+			t.Errorf("%s %s: expected no params without a match, got %d", test.method, test.path, rctx.ParamCount(ctx))
+		}
+		for k, v := range test.params {
+			if got := rctx.GetParam(ctx, k); got != v {
+				t.Errorf("%s %s: expected param %s=%q, got %q", test.method, test.path, k, v, got)
+			}
+		}
+		rctx.Release(ctx)
+	}
+	// Bind without a context matches like Match.
+	if leaf_id := rtree.Bind(httptest.NewRequest(http.MethodGet, "/files/a", nil), nil); leaf_id != 3 {
+		t.Errorf("expected leaf 3 without a context, got %d", leaf_id)
+	}
+}
+
+func TestStaticIndex(t *testing.T) {
+	rtree := New()
+	rtree.Add(route.Declare(http.MethodGet, "/users/me"))
+	rtree.Add(route.Declare(http.MethodGet, "/users/[id]"))
+	rtree.Add(route.Declare(http.MethodGet, "/users/new"))
+	rtree.Add(route.Declare(http.MethodGet, "/users/Keys", route.CaseInsensitive()))
+	rtree.Add(route.Declare(http.MethodGet, "/users/[id]/+"))
+	rtree.Add(route.Declare(http.MethodGet, "/users/me/settings"))
+	// Enough children for /users to be matched with its index.
+	for i := 0; i < indexedChildren; i++ {
+		rtree.Add(route.Declare(http.MethodGet, fmt.Sprintf("/users/u%d", i)))
+	}
+	for i := 0; i < 100; i++ {
+		rtree.Add(route.Declare(http.MethodGet, fmt.Sprintf("/r%d", i)))
+	}
+	tests := []struct {
+		path    string
+		leaf_id int
+	}{
+		{"/users/me", 1},
+		// Dynamic siblings registered before a static one still match first.
+		{"/users/new", 2},
+		// Case-insensitive routes fold all of their static parts, so they're in their own subtree.
+		{"/USERS/KEYS", 4},
+		// The Kelvin sign folds to k.
+		{"/USERS/\u212Aeys", 4},
+		{"/users/keys/a", 5},
+		{"/users/me/settings", 5},
+		{"/users/u3", 2},
+		{"/r42", 6 + indexedChildren + 43},
+		{"/r100", NO_LEAF_ID},
+	}
+	for _, test := range tests {
+		if leaf_id := rtree.Match(httptest.NewRequest(http.MethodGet, test.path, nil)); leaf_id != test.leaf_id {
+			t.Errorf("%s: expected leaf %d, got %d", test.path, test.leaf_id, leaf_id)
+		}
+	}
+	// Removing and prioritizing keep the index in order.
+	rtree.Remove(2)
+	if leaf_id := rtree.Match(httptest.NewRequest(http.MethodGet, "/users/new", nil)); leaf_id != 3 {
+		t.Errorf("expected static route after removing wildcard, got %d", leaf_id)
+	}
+	rtree.Add(route.Declare(http.MethodGet, "/users/[id]"))
+	rtree.SetPriority(true)
+	if leaf_id := rtree.Match(httptest.NewRequest(http.MethodGet, "/users/me/settings", nil)); leaf_id != 6 {
+		t.Errorf("expected static route in priority mode, got %d", leaf_id)
+	}
+	c := rtree.Clone()
+	if leaf_id := c.Match(httptest.NewRequest(http.MethodGet, "/Users/KEYS", nil)); leaf_id != 4 {
+		t.Errorf("expected cloned index to match, got %d", leaf_id)
+	}
+}
+
+func TestRadix(t *testing.T) {
+	rtree := New()
+	rtree.Add(route.Declare(http.MethodGet, "/api/v1/internal/users/list"))
+	root := rtree.methodRoot[http.MethodGet]
+	if run := root.children[0]; run.edge != "/api/v1/internal/users" || len(run.children) != 1 {
+		t.Fatalf("expected static parts before the leaf to share a node, got %q", run.edge)
+	}
+	rtree.Add(route.Declare(http.MethodGet, "/api/v1/internal/users/[id]"))
+	rtree.Add(route.Declare(http.MethodGet, "/api/v1/public/status/"))
+	rtree.Add(route.Declare(http.MethodGet, "/api/v1/internal/[svc]/health/live"))
+	rtree.Add(route.Declare(http.MethodGet, "/api/v1/internal"))
+	rtree.Add(route.Declare(http.MethodGet, "/Admin/Users/Keys", route.CaseInsensitive()))
+	rtree.Add(route.Declare(http.MethodGet, "/api/v1/files/[path]+"))
+	if run := root.children[0]; run.edge != "/api/v1" {
+		t.Errorf("expected run to split where routes branch off, got %q", run.edge)
+	}
+	tests := []struct {
+		path    string
+		leaf_id int
+	}{
+		{"/api/v1/internal/users/list", 1},
+		{"/api/v1/internal/users/12", 2},
+		{"/api/v1/public/status/", 3},
+		{"/api/v1/public/status", NO_LEAF_ID},
+		{"/api/v1/internal/billing/health/live", 4},
+		{"/api/v1/internal/users/health/live", 4},
+		{"/api/v1/internal", 5},
+		{"/api/v1/internal/", NO_LEAF_ID},
+		{"/api/v1/internals/users/list", NO_LEAF_ID},
+		{"/api/v1", NO_LEAF_ID},
+		// Consecutive slashes are matched token by token.
+		{"/api//v1///internal/users/list", 1},
+		{"/ADMIN/users/KEYS", 6},
+		{"/admin/users/Keys", 6},
+		{"/api/v1/files/a/b", 7},
+		{"/API/v1/internal", NO_LEAF_ID},
+	}
+	check := func(rtree *RouteTree) {
+		for _, test := range tests {
+			if leaf_id := rtree.Match(httptest.NewRequest(http.MethodGet, test.path, nil)); leaf_id != test.leaf_id {
+				t.Errorf("%s: expected leaf %d, got %d", test.path, test.leaf_id, leaf_id)
+			}
+		}
+	}
+	check(rtree)
+	check(rtree.Clone())
+	if order := rtree.Order(http.MethodGet); !reflect.DeepEqual(order, []int{1, 2, 4, 3, 5, 7, 6}) {
+		t.Errorf("expected split runs to keep their routes in order, got %v", order)
+	}
+	rtree.Remove(1)
+	rtree.Remove(2)
+	if leaf_id := rtree.Match(httptest.NewRequest(http.MethodGet, "/api/v1/internal/users/health/live", nil)); leaf_id != 4 {
+		t.Errorf("expected leaf 4 after removing routes, got %d", leaf_id)
+	}
+	rtree.Add(route.Declare(http.MethodGet, "/api/v1/internal/users/health/live"))
+	rtree.SetPriority(true)
+	if leaf_id := rtree.Match(httptest.NewRequest(http.MethodGet, "/api/v1/internal/users/health/live", nil)); leaf_id != 8 {
+		t.Errorf("expected static run before wildcard in priority mode, got %d", leaf_id)
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	rtree := New()
+	rtree.Add(route.Declare(http.MethodGet, "/status", route.Require(require.Hosts("internal.com"))))
+	rtree.Add(route.Declare(http.MethodGet, "/status"))
+	rtree.Add(route.Declare(http.MethodGet, "/health"))
+	rtree.SetFallback(http.MethodHead, http.MethodGet)
+	if leaf_id := rtree.Match(httptest.NewRequest(http.MethodGet, "/status", nil)); leaf_id != 2 {
+		t.Errorf("expected leaf 2, got %d", leaf_id)
+	}
+	// Paths are matched as if every requirement were met.
+	if leaf_id := rtree.MatchPath(http.MethodGet, "/status"); leaf_id != 1 {
+		t.Errorf("expected leaf 1, got %d", leaf_id)
+	}
+	if leaf_id := rtree.MatchPath(http.MethodHead, "/health"); leaf_id != 3 {
+		t.Errorf("expected fallback leaf 3, got %d", leaf_id)
+	}
+	if leaf_id := rtree.MatchPath(http.MethodPost, "/health"); leaf_id != NO_LEAF_ID {
+		t.Errorf("expected no match, got %d", leaf_id)
+	}
+}